@@ -0,0 +1,258 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	ht "github.com/nytimes/httptest/internal"
+)
+
+// runCommand runs a subcommand, returning false if args don't name one
+func runCommand(args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+
+	switch args[0] {
+	case "generate":
+		return true, generateCommand(args[1:])
+	case "import":
+		return true, importCommand(args[1:])
+	case "serve":
+		return true, serveCommand(args[1:])
+	case "record":
+		return true, recordCommand(args[1:])
+	}
+
+	return false, nil
+}
+
+// generateCommand generates test files from other sources
+func generateCommand(args []string) error {
+	if len(args) == 0 || args[0] != "openapi" {
+		return fmt.Errorf("usage: httptest generate openapi [-o output.yml] spec.yml")
+	}
+
+	flags := flag.NewFlagSet("generate openapi", flag.ExitOnError)
+	output := flags.String("o", "", "file to write tests to. Default: stdout")
+	flags.Parse(args[1:])
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: httptest generate openapi [-o output.yml] spec.yml")
+	}
+
+	tf, err := ht.GenerateFromOpenAPI(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	return writeTestFile(tf, *output, fmt.Sprintf("Generated from %s", flags.Arg(0)))
+}
+
+// importCommand converts HAR files or curl commands into tests
+func importCommand(args []string) error {
+	usage := fmt.Errorf("usage: httptest import har [options] file.har\n       httptest import curl [options] ['curl ...']")
+	if len(args) == 0 || (args[0] != "har" && args[0] != "curl") {
+		return usage
+	}
+	source := args[0]
+
+	flags := flag.NewFlagSet("import "+source, flag.ExitOnError)
+	output := flags.String("o", "", "file to write tests to. Default: stdout")
+	strip := flags.String("strip-headers", strings.Join(ht.DefaultStripHeaders, ","), "comma-separated request and response headers to leave out")
+	assert := flags.String("assert-headers", strings.Join(ht.DefaultAssertHeaders, ","), "comma-separated response headers to assert on (HAR only)")
+	flags.Parse(args[1:])
+
+	opts := ht.ImportOptions{
+		StripHeaders:  ht.SplitList(*strip),
+		AssertHeaders: ht.SplitList(*assert),
+	}
+
+	var tf *ht.TestFile
+	switch source {
+	case "har":
+		if flags.NArg() != 1 {
+			return usage
+		}
+		data, err := os.ReadFile(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		if tf, err = ht.ImportHAR(data, opts); err != nil {
+			return err
+		}
+	case "curl":
+		// The command is read from the arguments, or stdin if there are none
+		command := strings.Join(flags.Args(), " ")
+		if flags.NArg() == 0 {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return err
+			}
+			command = string(data)
+		}
+		test, err := ht.ImportCurl(command, opts)
+		if err != nil {
+			return err
+		}
+		tf = &ht.TestFile{Tests: []*ht.Test{test}}
+	}
+
+	return writeTestFile(tf, *output, fmt.Sprintf("Imported from %s", source))
+}
+
+// serveCommand serves the mock responses of tests until interrupted
+func serveCommand(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", "localhost:8080", "address to listen on")
+	verify := flags.Bool("verify", false, "fail if a mock was not served before the server is stopped")
+	flags.Parse(args)
+
+	if flags.NArg() > 1 {
+		return fmt.Errorf("usage: httptest serve [-addr host:port] [-verify] [tests]")
+	}
+	path := os.Getenv("TEST_DIRECTORY")
+	if flags.NArg() == 1 {
+		path = flags.Arg(0)
+	} else if len(path) == 0 {
+		path = "tests"
+	}
+
+	tests, err := ht.ParseAllTestsInDirectory(path)
+	if err != nil {
+		return err
+	}
+	mockServer, err := ht.NewMockServer(tests, os.Stdout)
+	if err != nil {
+		return fmt.Errorf("%s in %s", err, path)
+	}
+
+	server := &http.Server{Addr: *addr, Handler: mockServer}
+	if err := listenUntilStopped(server, fmt.Sprintf("serving mocks of %s on http://%s", path, *addr)); err != nil {
+		return err
+	}
+
+	// Report which mocks were served
+	unused := 0
+	fmt.Printf("\nmocks served:\n")
+	for _, hit := range mockServer.Hits() {
+		fmt.Printf("%4d  %s | %s | %s %s\n", hit.Hits, hit.Filename, hit.Description, hit.Method, hit.Path)
+		if hit.Hits == 0 {
+			unused++
+		}
+	}
+
+	if *verify && unused > 0 {
+		return fmt.Errorf("%d mocks were not served", unused)
+	}
+	return nil
+}
+
+// recordCommand records the traffic of a reverse proxy as tests until interrupted
+func recordCommand(args []string) error {
+	usage := fmt.Errorf("usage: httptest record [options] https://target.example.com")
+
+	flags := flag.NewFlagSet("record", flag.ExitOnError)
+	addr := flags.String("addr", "localhost:8080", "address to listen on")
+	output := flags.String("o", "", "file to write tests to. Default: stdout")
+	snapshots := flags.Bool("snapshots", false, "compare response bodies with snapshots written beside the output file")
+	strip := flags.String("strip-headers", strings.Join(ht.DefaultStripHeaders, ","), "comma-separated request and response headers to leave out")
+	assert := flags.String("assert-headers", strings.Join(ht.DefaultRecordHeaders, ","), "comma-separated response headers to assert on")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return usage
+	}
+	if *snapshots && len(*output) == 0 {
+		return fmt.Errorf("-snapshots requires -o")
+	}
+
+	recorder, err := ht.NewRecorder(flags.Arg(0), ht.RecordOptions{
+		ImportOptions: ht.ImportOptions{
+			StripHeaders:  ht.SplitList(*strip),
+			AssertHeaders: ht.SplitList(*assert),
+		},
+		Snapshots: *snapshots,
+	}, os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Addr: *addr, Handler: recorder}
+	if err := listenUntilStopped(server, fmt.Sprintf("recording %s on http://%s", flags.Arg(0), *addr)); err != nil {
+		return err
+	}
+
+	tf := recorder.TestFile()
+	if len(tf.Tests) == 0 {
+		return fmt.Errorf("no requests were recorded")
+	}
+	if *snapshots {
+		if err := recorder.WriteSnapshots(filepath.Dir(*output)); err != nil {
+			return err
+		}
+	}
+
+	return writeTestFile(tf, *output, fmt.Sprintf("Recorded from %s", flags.Arg(0)))
+}
+
+// listenUntilStopped serves until the process is interrupted or terminated
+func listenUntilStopped(server *http.Server, message string) error {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		fmt.Fprintln(os.Stderr, message)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	case <-stop:
+		server.Shutdown(context.Background())
+	}
+	return nil
+}
+
+// writeTestFile writes a test file as YAML to a file, or stdout if path is empty
+func writeTestFile(tf *ht.TestFile, path, comment string) error {
+	data, err := ht.MarshalTestFile(tf)
+	if err != nil {
+		return err
+	}
+	data = append([]byte(fmt.Sprintf("# %s\n", comment)), data...)
+
+	if len(path) == 0 {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
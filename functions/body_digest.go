@@ -0,0 +1,40 @@
+package functions
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+)
+
+var digestHashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// BodyDigest returns the digest of the request body using the given algorithm (md5, sha1, sha256 or sha512), in the
+// given encoding (hex, base64 or base64url).
+func BodyDigest(request *RequestContext, args []string) (string, error) {
+	if len(args) != 2 {
+		return "", fmt.Errorf("error calling BodyDigest; 2 arguments are needed (algorithm and encoding)")
+	}
+
+	newHash, ok := digestHashes[args[0]]
+	if !ok {
+		return "", fmt.Errorf("error calling BodyDigest; unknown algorithm %s; supported algorithms are md5, sha1, sha256 and sha512", args[0])
+	}
+
+	digest := newHash()
+	digest.Write(request.Body)
+
+	encoded, err := encodeSignature(digest.Sum(nil), args[1])
+	if err != nil {
+		return "", fmt.Errorf("error calling BodyDigest; %w", err)
+	}
+
+	return encoded, nil
+}
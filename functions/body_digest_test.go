@@ -0,0 +1,40 @@
+package functions
+
+import (
+	"testing"
+)
+
+func TestBodyDigest(t *testing.T) {
+	var tests = []struct {
+		body     string
+		args     []string
+		expected string
+	}{
+		{`{"hello": "world"}`, []string{"sha256", "base64"}, "X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE="},
+		{`{"hello": "world"}`, []string{"md5", "base64"}, "Sd/dVLAcvNLSq16eXua5uQ=="},
+		{"", []string{"md5", "hex"}, "d41d8cd98f00b204e9800998ecf8427e"},
+	}
+
+	for _, tc := range tests {
+		actual, err := BodyDigest(&RequestContext{Body: []byte(tc.body)}, tc.args)
+		if err != nil {
+			t.Errorf("BodyDigest(%s, %v): unexpected error %v", tc.body, tc.args, err)
+		}
+		if actual != tc.expected {
+			t.Errorf("BodyDigest(%s, %v): expected %v, actual %v", tc.body, tc.args, tc.expected, actual)
+		}
+	}
+
+	var errorTests = [][]string{
+		{},
+		{"sha256"},
+		{"sha384", "hex"},
+		{"sha256", "base32"},
+	}
+
+	for _, args := range errorTests {
+		if _, err := BodyDigest(&RequestContext{}, args); err == nil {
+			t.Errorf("BodyDigest(%v): expected error", args)
+		}
+	}
+}
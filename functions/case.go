@@ -0,0 +1,24 @@
+package functions
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Lower returns the arg in lower case, substituting a previously defined header if available.
+func Lower(existingHeaders map[string]string, args []string) (string, error) {
+	if !validateSingleArg(args) {
+		return "", fmt.Errorf("error calling Lower; 1 argument is needed (a value)")
+	}
+
+	return strings.ToLower(argValue(existingHeaders, args[0])), nil
+}
+
+// Upper returns the arg in upper case, substituting a previously defined header if available.
+func Upper(existingHeaders map[string]string, args []string) (string, error) {
+	if !validateSingleArg(args) {
+		return "", fmt.Errorf("error calling Upper; 1 argument is needed (a value)")
+	}
+
+	return strings.ToUpper(argValue(existingHeaders, args[0])), nil
+}
@@ -0,0 +1,38 @@
+package functions
+
+import (
+	"testing"
+)
+
+func TestCaseFunctions(t *testing.T) {
+	existingHeaders := map[string]string{"x-previous-header": "MiXeD"}
+
+	var tests = []struct {
+		name     string
+		fn       func(map[string]string, []string) (string, error)
+		args     []string
+		expected string
+	}{
+		{"Lower", Lower, []string{"Hello World"}, "hello world"},
+		{"Lower", Lower, []string{"x-previous-header"}, "mixed"},
+		{"Upper", Upper, []string{"Hello World"}, "HELLO WORLD"},
+		{"Upper", Upper, []string{"x-previous-header"}, "MIXED"},
+	}
+
+	for _, tc := range tests {
+		actual, err := tc.fn(existingHeaders, tc.args)
+		if err != nil {
+			t.Errorf("%s(%v): unexpected error %v", tc.name, tc.args, err)
+		}
+		if actual != tc.expected {
+			t.Errorf("%s(%v): expected %v, actual %v", tc.name, tc.args, tc.expected, actual)
+		}
+	}
+
+	if _, err := Lower(existingHeaders, []string{}); err == nil {
+		t.Errorf("Lower([]): expected error")
+	}
+	if _, err := Upper(existingHeaders, []string{"a", "b"}); err == nil {
+		t.Errorf("Upper([a b]): expected error")
+	}
+}
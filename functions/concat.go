@@ -9,12 +9,16 @@ func Concat(existingHeaders map[string]string, args []string) (string, error) {
 	var buffer strings.Builder
 
 	for _, arg := range args {
-		if value, ok := existingHeaders[arg]; ok {
-			buffer.WriteString(value)
-		} else {
-			buffer.WriteString(arg)
-		}
+		buffer.WriteString(argValue(existingHeaders, arg))
 	}
 
 	return buffer.String(), nil
 }
+
+// Returns the value of a previously defined header if the arg names one, or else the arg itself.
+func argValue(existingHeaders map[string]string, arg string) string {
+	if value, ok := existingHeaders[arg]; ok {
+		return value
+	}
+	return arg
+}
@@ -0,0 +1,92 @@
+package functions
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Base64 encodings by name.
+var base64Encodings = map[string]*base64.Encoding{
+	"std":    base64.StdEncoding,
+	"raw":    base64.RawStdEncoding,
+	"url":    base64.URLEncoding,
+	"rawurl": base64.RawURLEncoding,
+}
+
+// Base64Encode returns the base64 encoding of the last arg, substituting a previously defined header if available.
+// An optional first arg selects the encoding (std, raw, url or rawurl). Default: std.
+func Base64Encode(existingHeaders map[string]string, args []string) (string, error) {
+	encoding, value, err := base64Args("Base64Encode", existingHeaders, args)
+	if err != nil {
+		return "", err
+	}
+
+	return encoding.EncodeToString([]byte(value)), nil
+}
+
+// Base64Decode decodes the base64 encoded last arg, substituting a previously defined header if available. An
+// optional first arg selects the encoding (std, raw, url or rawurl). Default: std.
+func Base64Decode(existingHeaders map[string]string, args []string) (string, error) {
+	encoding, value, err := base64Args("Base64Decode", existingHeaders, args)
+	if err != nil {
+		return "", err
+	}
+
+	decoded, err := encoding.DecodeString(value)
+	if err != nil {
+		return "", fmt.Errorf("error calling Base64Decode; %w", err)
+	}
+	return string(decoded), nil
+}
+
+// Gets the encoding and value from the args.
+func base64Args(name string, existingHeaders map[string]string, args []string) (*base64.Encoding, string, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, "", fmt.Errorf("error calling %s; 1 or 2 arguments are needed (optionally an encoding, and a value)", name)
+	}
+
+	encoding := base64.StdEncoding
+	if len(args) == 2 {
+		var ok bool
+		if encoding, ok = base64Encodings[args[0]]; !ok {
+			return nil, "", fmt.Errorf("error calling %s; unknown encoding %q, must be std, raw, url or rawurl", name, args[0])
+		}
+	}
+
+	return encoding, argValue(existingHeaders, args[len(args)-1]), nil
+}
+
+// URLEncode escapes the arg for use in a URL query, substituting a previously defined header if available.
+func URLEncode(existingHeaders map[string]string, args []string) (string, error) {
+	if !validateSingleArg(args) {
+		return "", fmt.Errorf("error calling URLEncode; 1 argument is needed (a value)")
+	}
+
+	return url.QueryEscape(argValue(existingHeaders, args[0])), nil
+}
+
+// JSONEscape escapes the arg for use inside a JSON string, substituting a previously defined header if available.
+func JSONEscape(existingHeaders map[string]string, args []string) (string, error) {
+	if !validateSingleArg(args) {
+		return "", fmt.Errorf("error calling JSONEscape; 1 argument is needed (a value)")
+	}
+
+	var buf strings.Builder
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(argValue(existingHeaders, args[0])); err != nil {
+		return "", fmt.Errorf("error calling JSONEscape; %w", err)
+	}
+
+	// Drop the quotes and newline around the encoded string
+	encoded := strings.TrimSuffix(buf.String(), "\n")
+	return encoded[1 : len(encoded)-1], nil
+}
+
+// Validates a single argument is provided.
+func validateSingleArg(args []string) bool {
+	return len(args) == 1
+}
@@ -0,0 +1,57 @@
+package functions
+
+import (
+	"testing"
+)
+
+func TestEncodingFunctions(t *testing.T) {
+	existingHeaders := map[string]string{"x-previous-header": "a b&c"}
+
+	var tests = []struct {
+		name     string
+		fn       func(map[string]string, []string) (string, error)
+		args     []string
+		expected string
+	}{
+		{"Base64Encode", Base64Encode, []string{"hello?"}, "aGVsbG8/"},
+		{"Base64Encode", Base64Encode, []string{"url", "hello?"}, "aGVsbG8_"},
+		{"Base64Encode", Base64Encode, []string{"raw", "hi"}, "aGk"},
+		{"Base64Encode", Base64Encode, []string{"rawurl", "x-previous-header"}, "YSBiJmM"},
+		{"Base64Decode", Base64Decode, []string{"aGVsbG8/"}, "hello?"},
+		{"Base64Decode", Base64Decode, []string{"rawurl", "aGVsbG8_"}, "hello?"},
+		{"URLEncode", URLEncode, []string{"a b&c=d/é"}, "a+b%26c%3Dd%2F%C3%A9"},
+		{"URLEncode", URLEncode, []string{"x-previous-header"}, "a+b%26c"},
+		{"JSONEscape", JSONEscape, []string{"say \"hi\"\n<tab>\t"}, `say \"hi\"\n<tab>\t`},
+		{"JSONEscape", JSONEscape, []string{""}, ""},
+	}
+
+	for _, tc := range tests {
+		actual, err := tc.fn(existingHeaders, tc.args)
+		if err != nil {
+			t.Errorf("%s(%v): unexpected error %v", tc.name, tc.args, err)
+		}
+		if actual != tc.expected {
+			t.Errorf("%s(%v): expected %v, actual %v", tc.name, tc.args, tc.expected, actual)
+		}
+	}
+
+	var errorTests = []struct {
+		name string
+		fn   func(map[string]string, []string) (string, error)
+		args []string
+	}{
+		{"Base64Encode", Base64Encode, []string{}},
+		{"Base64Encode", Base64Encode, []string{"base32", "hi"}},
+		{"Base64Decode", Base64Decode, []string{"not base64!"}},
+		{"Base64Decode", Base64Decode, []string{"std", "aGk", "extra"}},
+		{"URLEncode", URLEncode, []string{}},
+		{"URLEncode", URLEncode, []string{"a", "b"}},
+		{"JSONEscape", JSONEscape, []string{}},
+	}
+
+	for _, tc := range errorTests {
+		if _, err := tc.fn(existingHeaders, tc.args); err == nil {
+			t.Errorf("%s(%v): expected error", tc.name, tc.args)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package functions
+
+import (
+	"fmt"
+	"os"
+)
+
+// Env returns the value of an environment variable, or the default value given as the second arg if it is not set.
+func Env(existingHeaders map[string]string, args []string) (string, error) {
+	if !validateEnv(args) {
+		return "", fmt.Errorf("error calling Env; 1 or 2 arguments are needed (name, and optionally a default value)")
+	}
+
+	if value, ok := os.LookupEnv(args[0]); ok {
+		return value, nil
+	}
+	if len(args) == 2 {
+		return args[1], nil
+	}
+	return "", fmt.Errorf("error calling Env; %s is not set and no default value is given", args[0])
+}
+
+// Validates the required number of arguments are provided (name, and optionally a default value).
+func validateEnv(args []string) bool {
+	return len(args) == 1 || len(args) == 2
+}
@@ -0,0 +1,43 @@
+package functions
+
+import (
+	"testing"
+)
+
+func TestEnv(t *testing.T) {
+	t.Setenv("HTTPTEST_ENV_SET", "value")
+	t.Setenv("HTTPTEST_ENV_EMPTY", "")
+
+	var tests = []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"HTTPTEST_ENV_SET"}, "value"},
+		{[]string{"HTTPTEST_ENV_SET", "default"}, "value"},
+		{[]string{"HTTPTEST_ENV_EMPTY", "default"}, ""},
+		{[]string{"HTTPTEST_ENV_UNSET", "default"}, "default"},
+		{[]string{"HTTPTEST_ENV_UNSET", ""}, ""},
+	}
+
+	for _, tc := range tests {
+		actual, err := Env(map[string]string{}, tc.args)
+		if err != nil {
+			t.Errorf("Env(%v): unexpected error %v", tc.args, err)
+		}
+		if actual != tc.expected {
+			t.Errorf("Env(%v): expected %v, actual %v", tc.args, tc.expected, actual)
+		}
+	}
+
+	var errorTests = [][]string{
+		{},
+		{"HTTPTEST_ENV_UNSET"},
+		{"HTTPTEST_ENV_SET", "default", "extra"},
+	}
+
+	for _, args := range errorTests {
+		if _, err := Env(map[string]string{}, args); err == nil {
+			t.Errorf("Env(%v): expected error", args)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package functions
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// SHA256Hex returns the SHA-256 digest of the arg in hex, substituting a previously defined header if available.
+func SHA256Hex(existingHeaders map[string]string, args []string) (string, error) {
+	if !validateSingleArg(args) {
+		return "", fmt.Errorf("error calling SHA256Hex; 1 argument is needed (a value)")
+	}
+
+	digest := sha256.Sum256([]byte(argValue(existingHeaders, args[0])))
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// MD5 returns the MD5 digest of the arg in hex, substituting a previously defined header if available.
+func MD5(existingHeaders map[string]string, args []string) (string, error) {
+	if !validateSingleArg(args) {
+		return "", fmt.Errorf("error calling MD5; 1 argument is needed (a value)")
+	}
+
+	digest := md5.Sum([]byte(argValue(existingHeaders, args[0])))
+	return hex.EncodeToString(digest[:]), nil
+}
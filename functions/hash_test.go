@@ -0,0 +1,40 @@
+package functions
+
+import (
+	"testing"
+)
+
+func TestHashFunctions(t *testing.T) {
+	existingHeaders := map[string]string{"x-previous-header": "abc"}
+
+	var tests = []struct {
+		name     string
+		fn       func(map[string]string, []string) (string, error)
+		args     []string
+		expected string
+	}{
+		{"SHA256Hex", SHA256Hex, []string{""}, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{"SHA256Hex", SHA256Hex, []string{"x-previous-header"}, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"MD5", MD5, []string{""}, "d41d8cd98f00b204e9800998ecf8427e"},
+		{"MD5", MD5, []string{"abc"}, "900150983cd24fb0d6963f7d28e17f72"},
+	}
+
+	for _, tc := range tests {
+		actual, err := tc.fn(existingHeaders, tc.args)
+		if err != nil {
+			t.Errorf("%s(%v): unexpected error %v", tc.name, tc.args, err)
+		}
+		if actual != tc.expected {
+			t.Errorf("%s(%v): expected %v, actual %v", tc.name, tc.args, tc.expected, actual)
+		}
+	}
+
+	for _, fn := range []func(map[string]string, []string) (string, error){SHA256Hex, MD5} {
+		if _, err := fn(existingHeaders, []string{}); err == nil {
+			t.Errorf("expected error without arguments")
+		}
+		if _, err := fn(existingHeaders, []string{"a", "b"}); err == nil {
+			t.Errorf("expected error with 2 arguments")
+		}
+	}
+}
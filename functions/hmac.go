@@ -0,0 +1,72 @@
+package functions
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+)
+
+// HMACSHA256 constructs a string from given args (delimited by newlines), and returns its HMAC-SHA256 using the key
+// in the given encoding (hex, base64 or base64url).
+func HMACSHA256(existingHeaders map[string]string, args []string) (string, error) {
+	return signStringHMAC("HMACSHA256", sha256.New, existingHeaders, args)
+}
+
+// HMACSHA512 constructs a string from given args (delimited by newlines), and returns its HMAC-SHA512 using the key
+// in the given encoding (hex, base64 or base64url).
+func HMACSHA512(existingHeaders map[string]string, args []string) (string, error) {
+	return signStringHMAC("HMACSHA512", sha512.New, existingHeaders, args)
+}
+
+// HMACSHA1 constructs a string from given args (delimited by newlines), and returns its HMAC-SHA1 using the key
+// in the given encoding (hex, base64 or base64url).
+func HMACSHA1(existingHeaders map[string]string, args []string) (string, error) {
+	return signStringHMAC("HMACSHA1", sha1.New, existingHeaders, args)
+}
+
+func signStringHMAC(name string, hashFunc func() hash.Hash, existingHeaders map[string]string, args []string) (string, error) {
+	if !validateSignStringHMAC(args) {
+		return "", fmt.Errorf("error calling %s; at least 3 arguments are needed (key, encoding, and a string to sign)", name)
+	}
+
+	if args[0] == "" {
+		return "", fmt.Errorf("error calling %s; key is empty", name)
+	}
+
+	// Construct the string to sign
+	stringToSign := argsToStringToSign(existingHeaders, args[2:])
+
+	mac := hmac.New(hashFunc, []byte(args[0]))
+	mac.Write([]byte(stringToSign))
+
+	signature, err := encodeSignature(mac.Sum(nil), args[1])
+	if err != nil {
+		return "", fmt.Errorf("error calling %s; %w", name, err)
+	}
+
+	return signature, nil
+}
+
+// Validates the required number of arguments are provided (key, encoding, and a string to sign).
+func validateSignStringHMAC(args []string) bool {
+	return len(args) > 2
+}
+
+// Encodes a signature as hex (the default), base64, or unpadded base64url.
+func encodeSignature(signature []byte, encoding string) (string, error) {
+	switch encoding {
+	case "", "hex":
+		return hex.EncodeToString(signature), nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString(signature), nil
+	case "base64url":
+		return base64.RawURLEncoding.EncodeToString(signature), nil
+	default:
+		return "", fmt.Errorf("unknown encoding %s; supported encodings are hex, base64 and base64url", encoding)
+	}
+}
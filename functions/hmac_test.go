@@ -0,0 +1,86 @@
+package functions
+
+import (
+	"testing"
+)
+
+func TestHMAC(t *testing.T) {
+	var tests = []struct {
+		fn              func(map[string]string, []string) (string, error)
+		existingHeaders map[string]string
+		args            []string
+		expected        string
+	}{
+		{
+			HMACSHA256,
+			map[string]string{},
+			[]string{"key", "hex", "The quick brown fox jumps over the lazy dog"},
+			"abb26bc1a42fb16503a4d5b44d5082ed8fe79229b3c057c6d770956777b83b15",
+		},
+		{
+			HMACSHA256,
+			map[string]string{},
+			[]string{"secret", "", ""},
+			"9a0e5161db5c3c33fff7116bf46ddb704661eece965fd7805f0216052b561728",
+		},
+		{
+			HMACSHA512,
+			map[string]string{
+				"x-timestamp": "123",
+			},
+			[]string{"key", "base64", "GET", "/path", "x-timestamp"},
+			"4CKW0soZIEUuLMEDumWymP+LMl7Rz/AEO4bcX1t67XshOavskIH9XLrsw83cnX1LtWHcOZe086HQuxTZaWaBoQ==",
+		},
+		{
+			HMACSHA1,
+			map[string]string{},
+			[]string{"key", "base64url", "a", "b"},
+			"HeggSCeMqOcprIJKCTaT_08Ug8E",
+		},
+	}
+
+	for _, tc := range tests {
+		actual, err := tc.fn(tc.existingHeaders, tc.args)
+		if err != nil {
+			t.Errorf("HMAC(%v, %v): unexpected error %v", tc.existingHeaders, tc.args, err)
+		}
+		if actual != tc.expected {
+			t.Errorf("HMAC(%v, %v): expected %v, actual %v", tc.existingHeaders, tc.args, tc.expected, actual)
+		}
+	}
+}
+
+func TestHMACErrors(t *testing.T) {
+	var tests = [][]string{
+		{},
+		{"key", "hex"},
+		{"", "hex", "string"},
+		{"key", "base32", "string"},
+	}
+
+	for _, args := range tests {
+		if _, err := HMACSHA256(map[string]string{}, args); err == nil {
+			t.Errorf("HMACSHA256(%v): expected error", args)
+		}
+	}
+}
+
+func TestValidateSignStringHMAC(t *testing.T) {
+	var tests = []struct {
+		args  []string
+		valid bool
+	}{
+		{[]string{}, false},
+		{[]string{"key"}, false},
+		{[]string{"key", "hex"}, false},
+		{[]string{"key", "hex", "string"}, true},
+		{[]string{"key", "", "string", "string"}, true},
+	}
+
+	for _, tc := range tests {
+		actual := validateSignStringHMAC(tc.args)
+		if actual != tc.valid {
+			t.Errorf("validateSignStringHMAC(%s): expected %v, actual %v", tc.args, tc.valid, actual)
+		}
+	}
+}
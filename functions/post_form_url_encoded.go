@@ -20,6 +20,13 @@ var errURLEmpty = errors.New("error calling PostFormURLEncoded; URL is empty")
 // PostFormURLEncoded sends an HTTP POST request to a given URL with the data provided,
 // returning either the whole response body or a specified JSON element.
 func PostFormURLEncoded(existingHeaders map[string]string, args []string) (string, error) {
+	return PostFormURLEncodedRequest(&RequestContext{Headers: existingHeaders}, args)
+}
+
+// PostFormURLEncodedRequest is PostFormURLEncoded for the request of a test,
+// sending the POST request with the transport of the request context.
+func PostFormURLEncodedRequest(request *RequestContext, args []string) (string, error) {
+	existingHeaders := request.Headers
 	// Get the URL, response element, and request body from the args
 	if len(args) == 0 {
 		return "", errURLMissing
@@ -42,7 +49,7 @@ func PostFormURLEncoded(existingHeaders map[string]string, args []string) (strin
 	}
 
 	// Send the request
-	client := &http.Client{}
+	client := &http.Client{Transport: request.transport()}
 	client.Timeout = time.Second * timeoutSeconds
 	response, err := client.PostForm(endpoint, requestBody)
 	if err != nil {
@@ -0,0 +1,89 @@
+package functions
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// Named character sets of RandomString.
+var charsets = map[string]string{
+	"alphanumeric": "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789",
+	"alpha":        "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz",
+	"numeric":      "0123456789",
+	"hex":          "0123456789abcdef",
+}
+
+// RandomString returns a random string of the given length, from a named character set (alphanumeric, alpha, numeric
+// or hex) or the characters of the second arg. Default: alphanumeric.
+func RandomString(existingHeaders map[string]string, args []string) (string, error) {
+	if !validateRandomString(args) {
+		return "", fmt.Errorf("error calling RandomString; 1 or 2 arguments are needed (length, and optionally a character set)")
+	}
+
+	length, err := strconv.Atoi(args[0])
+	if err != nil || length < 0 {
+		return "", fmt.Errorf("error calling RandomString; invalid length %q", args[0])
+	}
+
+	charset := charsets["alphanumeric"]
+	if len(args) == 2 {
+		if named, ok := charsets[args[1]]; ok {
+			charset = named
+		} else {
+			charset = args[1]
+		}
+	}
+	chars := []rune(charset)
+	if len(chars) == 0 {
+		return "", fmt.Errorf("error calling RandomString; character set is empty")
+	}
+
+	result := make([]rune, length)
+	for i := range result {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			return "", fmt.Errorf("error calling RandomString; %w", err)
+		}
+		result[i] = chars[n.Int64()]
+	}
+
+	return string(result), nil
+}
+
+// Validates the required number of arguments are provided (length, and optionally a character set).
+func validateRandomString(args []string) bool {
+	return len(args) == 1 || len(args) == 2
+}
+
+// RandomInt returns a random integer between min and max, inclusive.
+func RandomInt(existingHeaders map[string]string, args []string) (string, error) {
+	if !validateRandomInt(args) {
+		return "", fmt.Errorf("error calling RandomInt; 2 arguments are needed (min and max)")
+	}
+
+	min, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("error calling RandomInt; invalid min %q", args[0])
+	}
+	max, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("error calling RandomInt; invalid max %q", args[1])
+	}
+	if max < min {
+		return "", fmt.Errorf("error calling RandomInt; max %d is less than min %d", max, min)
+	}
+
+	n, err := rand.Int(rand.Reader, new(big.Int).Add(new(big.Int).Sub(big.NewInt(max), big.NewInt(min)), big.NewInt(1)))
+	if err != nil {
+		return "", fmt.Errorf("error calling RandomInt; %w", err)
+	}
+
+	return strconv.FormatInt(min+n.Int64(), 10), nil
+}
+
+// Validates the required number of arguments are provided (min and max).
+func validateRandomInt(args []string) bool {
+	return len(args) == 2
+}
@@ -0,0 +1,83 @@
+package functions
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestRandomString(t *testing.T) {
+	var tests = []struct {
+		args    []string
+		pattern string
+	}{
+		{[]string{"16"}, `^[A-Za-z0-9]{16}$`},
+		{[]string{"8", "numeric"}, `^[0-9]{8}$`},
+		{[]string{"8", "alpha"}, `^[A-Za-z]{8}$`},
+		{[]string{"32", "hex"}, `^[0-9a-f]{32}$`},
+		{[]string{"5", "xyz"}, `^[xyz]{5}$`},
+		{[]string{"0"}, `^$`},
+	}
+
+	for _, tc := range tests {
+		actual, err := RandomString(map[string]string{}, tc.args)
+		if err != nil {
+			t.Errorf("RandomString(%v): unexpected error %v", tc.args, err)
+		}
+		if !regexp.MustCompile(tc.pattern).MatchString(actual) {
+			t.Errorf("RandomString(%v): expected to match %s, actual %s", tc.args, tc.pattern, actual)
+		}
+	}
+
+	var errorTests = [][]string{
+		{},
+		{"abc"},
+		{"-1"},
+		{"8", ""},
+		{"8", "hex", "extra"},
+	}
+
+	for _, args := range errorTests {
+		if _, err := RandomString(map[string]string{}, args); err == nil {
+			t.Errorf("RandomString(%v): expected error", args)
+		}
+	}
+}
+
+func TestRandomInt(t *testing.T) {
+	var tests = []struct {
+		min, max int64
+	}{
+		{1, 6},
+		{-10, 10},
+		{5, 5},
+	}
+
+	for _, tc := range tests {
+		for i := 0; i < 20; i++ {
+			args := []string{strconv.FormatInt(tc.min, 10), strconv.FormatInt(tc.max, 10)}
+			actual, err := RandomInt(map[string]string{}, args)
+			if err != nil {
+				t.Fatalf("RandomInt(%v): unexpected error %v", args, err)
+			}
+			n, err := strconv.ParseInt(actual, 10, 64)
+			if err != nil || n < tc.min || n > tc.max {
+				t.Errorf("RandomInt(%v): expected an integer between %d and %d, actual %s", args, tc.min, tc.max, actual)
+			}
+		}
+	}
+
+	var errorTests = [][]string{
+		{},
+		{"1"},
+		{"a", "2"},
+		{"1", "b"},
+		{"6", "1"},
+	}
+
+	for _, args := range errorTests {
+		if _, err := RandomInt(map[string]string{}, args); err == nil {
+			t.Errorf("RandomInt(%v): expected error", args)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package functions
+
+import (
+	"fmt"
+	"os"
+)
+
+// ReadFile returns the contents of a file.
+func ReadFile(existingHeaders map[string]string, args []string) (string, error) {
+	if !validateSingleArg(args) {
+		return "", fmt.Errorf("error calling ReadFile; 1 argument is needed (a path)")
+	}
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return "", fmt.Errorf("error calling ReadFile; %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,34 @@
+package functions
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "body.json")
+	if err := os.WriteFile(path, []byte("{\"a\": 1}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := ReadFile(map[string]string{}, []string{path})
+	if err != nil {
+		t.Errorf("ReadFile(%s): unexpected error %v", path, err)
+	}
+	if actual != "{\"a\": 1}\n" {
+		t.Errorf("ReadFile(%s): unexpected contents %q", path, actual)
+	}
+
+	var errorTests = [][]string{
+		{},
+		{filepath.Join(t.TempDir(), "missing.json")},
+		{path, path},
+	}
+
+	for _, args := range errorTests {
+		if _, err := ReadFile(map[string]string{}, args); err == nil {
+			t.Errorf("ReadFile(%v): expected error", args)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package functions
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// RequestContext describes the request that a dynamic header is computed for.
+type RequestContext struct {
+	Method string
+	Scheme string
+	Host   string
+	Path   string
+	Query  url.Values
+	Body   []byte
+
+	// Headers are the headers set so far, including previous dynamic headers
+	Headers map[string]string
+
+	// Test is the test sending the request
+	Test TestInfo
+
+	// Transport sends the HTTP requests of functions. Default: http.DefaultTransport
+	Transport http.RoundTripper
+}
+
+// transport returns the transport for HTTP requests of functions
+func (r *RequestContext) transport() http.RoundTripper {
+	if r == nil || r.Transport == nil {
+		return http.DefaultTransport
+	}
+	return r.Transport
+}
+
+// TestInfo identifies a test.
+type TestInfo struct {
+	Filename    string
+	Description string
+}
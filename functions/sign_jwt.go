@@ -0,0 +1,212 @@
+package functions
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "crypto/sha256" // register SHA-256 for crypto.SHA256
+	_ "crypto/sha512" // register SHA-384 and SHA-512
+)
+
+// Returns the current time, replaced in tests.
+var timeNow = time.Now
+
+// Claims whose values are times, given as offsets from now or absolute Unix times.
+var timeClaims = map[string]bool{"iat": true, "nbf": true, "exp": true}
+
+// SignJWT builds a JWT from claims given as name=value args, and signs it with the key using the given algorithm
+// (RS256, RS384, PS256, ES256, EdDSA or HS256).
+func SignJWT(existingHeaders map[string]string, args []string) (string, error) {
+	if !validateSignJWT(args) {
+		return "", fmt.Errorf("error calling SignJWT; at least 3 arguments are needed (algorithm, key, and passphrase)")
+	}
+
+	alg := args[0]
+	header := map[string]interface{}{"alg": alg, "typ": "JWT"}
+	claims := map[string]interface{}{}
+
+	for _, arg := range args[3:] {
+		// Claims can be taken from previously set headers
+		if value, ok := existingHeaders[arg]; ok {
+			arg = value
+		}
+
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok || name == "" {
+			return "", fmt.Errorf("error calling SignJWT; claim %q is not in the form name=value", arg)
+		}
+
+		if headerName, ok := strings.CutPrefix(name, "header."); ok {
+			header[headerName] = claimValue(value)
+			continue
+		}
+
+		switch {
+		case timeClaims[name]:
+			t, err := timeClaim(value)
+			if err != nil {
+				return "", fmt.Errorf("error calling SignJWT; invalid %s claim: %w", name, err)
+			}
+			claims[name] = t
+		case name == "jti" && (value == "" || value == "random"):
+			jti, err := randomID()
+			if err != nil {
+				return "", fmt.Errorf("error calling SignJWT; unable to generate jti: %w", err)
+			}
+			claims[name] = jti
+		default:
+			claims[name] = claimValue(value)
+		}
+	}
+
+	// Encode the header and claims
+	encodedHeader, err := encodeJWTPart(header)
+	if err != nil {
+		return "", fmt.Errorf("error calling SignJWT; %w", err)
+	}
+	encodedClaims, err := encodeJWTPart(claims)
+	if err != nil {
+		return "", fmt.Errorf("error calling SignJWT; %w", err)
+	}
+	signingInput := encodedHeader + "." + encodedClaims
+
+	signature, err := signJWS(alg, args[1], args[2], []byte(signingInput))
+	if err != nil {
+		return "", fmt.Errorf("error calling SignJWT; %w", err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Validates the required number of arguments are provided (algorithm, key, and passphrase).
+func validateSignJWT(args []string) bool {
+	return len(args) > 2
+}
+
+// Signs the input with the algorithm, parsing the key as a PKCS #8 private key unless the algorithm uses HMAC.
+func signJWS(alg string, key string, passphrase string, input []byte) ([]byte, error) {
+	if alg == "HS256" {
+		secret, err := ReadKey(key)
+		if err != nil {
+			return nil, err
+		}
+		mac := hmac.New(crypto.SHA256.New, []byte(secret))
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	}
+
+	privateKey, err := LoadPrivateKey(key, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	switch alg {
+	case "RS256", "RS384", "PS256":
+		rsaKey, ok := privateKey.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("key is not an RSA key")
+		}
+
+		hash := crypto.SHA256
+		if alg == "RS384" {
+			hash = crypto.SHA384
+		}
+		digest := hash.New()
+		digest.Write(input)
+
+		if alg == "PS256" {
+			return rsa.SignPSS(rand.Reader, rsaKey, hash, digest.Sum(nil), &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		return rsa.SignPKCS1v15(nil, rsaKey, hash, digest.Sum(nil))
+
+	case "ES256":
+		ecKey, ok := privateKey.(*ecdsa.PrivateKey)
+		if !ok || ecKey.Curve.Params().BitSize != 256 {
+			return nil, fmt.Errorf("key is not a P-256 ECDSA key")
+		}
+
+		digest := crypto.SHA256.New()
+		digest.Write(input)
+		r, s, err := ecdsa.Sign(rand.Reader, ecKey, digest.Sum(nil))
+		if err != nil {
+			return nil, err
+		}
+
+		// JWS uses the fixed size concatenation of r and s rather than ASN.1
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return signature, nil
+
+	case "EdDSA":
+		edKey, ok := privateKey.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("key is not an Ed25519 key")
+		}
+		return ed25519.Sign(edKey, input), nil
+	}
+
+	return nil, fmt.Errorf("unsupported algorithm %s; supported algorithms are RS256, RS384, PS256, ES256, EdDSA and HS256", alg)
+}
+
+// Returns the value of a time claim: now, now plus an offset such as +5m or -30s, or a Unix time.
+func timeClaim(value string) (int64, error) {
+	now := timeNow()
+
+	switch {
+	case value == "" || value == "now":
+		return now.Unix(), nil
+	case strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-"):
+		offset, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, err
+		}
+		return now.Add(offset).Unix(), nil
+	}
+
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// Returns a claim value as JSON if it is a number, boolean, null, array, object or quoted string, or else as a string.
+func claimValue(value string) interface{} {
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.UseNumber()
+
+	var v interface{}
+	if err := decoder.Decode(&v); err == nil && !decoder.More() {
+		return v
+	}
+	return value
+}
+
+// Returns a random 128-bit ID in hex.
+func randomID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// Encodes a JWT header or claims set as unpadded base64url JSON.
+func encodeJWTPart(part map[string]interface{}) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(part); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes.TrimSuffix(buf.Bytes(), []byte("\n"))), nil
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/youmark/pkcs8"
@@ -30,11 +31,8 @@ func SignStringRS256PKCS8(existingHeaders map[string]string, args []string) (str
 	// Construct the string to sign
 	stringToSign := argsToStringToSign(existingHeaders, args[2:])
 
-	// Get the key in PEM format
-	pemBlock, _ := pem.Decode([]byte(key))
-
 	// Parse the key, decrypting it if necessary
-	decryptedKey, err := pkcs8.ParsePKCS8PrivateKey(pemBlock.Bytes, []byte(passphrase))
+	decryptedKey, err := parsePrivateKey(key, passphrase)
 	if err != nil {
 		return "", fmt.Errorf("error calling SignStringRS256PKCS8; unable to parse private key: %w", err)
 	}
@@ -92,6 +90,53 @@ func argsToKeyPassphrase(args []string) (string, string, error) {
 	return key, passphrase, nil
 }
 
+// Parses a (possibly passphrase-encrypted) PKCS #8 private key in PEM format.
+func parsePrivateKey(key string, passphrase string) (interface{}, error) {
+	pemBlock, _ := pem.Decode([]byte(key))
+	if pemBlock == nil {
+		return nil, errInvalidKeyFormat
+	}
+
+	return pkcs8.ParsePKCS8PrivateKey(pemBlock.Bytes, []byte(passphrase))
+}
+
+// ReadKey returns a key given directly or, if prefixed with "file:", read from a file.
+func ReadKey(key string) (string, error) {
+	path, ok := strings.CutPrefix(key, "file:")
+	if !ok {
+		if key == "" {
+			return "", fmt.Errorf("key is empty")
+		}
+		return key, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read key: %w", err)
+	}
+	return string(data), nil
+}
+
+// LoadPrivateKey loads a (possibly passphrase-encrypted) PKCS #8 private key in PEM format, given directly (with
+// newlines possibly converted to spaces) or, if prefixed with "file:", read from a file.
+func LoadPrivateKey(key string, passphrase string) (interface{}, error) {
+	key, err := ReadKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	formattedKey, err := formatKey(strings.TrimSpace(key), passphrase != "")
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := parsePrivateKey(formattedKey, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse private key: %w", err)
+	}
+	return privateKey, nil
+}
+
 // Validates the required number of arguments are provided (key, passphrase, and a string to sign).
 func validateSignStringRS256PKCS8(args []string) bool {
 	return len(args) > 2
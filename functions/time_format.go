@@ -0,0 +1,72 @@
+package functions
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Named layouts of TimeFormat, in addition to unix and unixMilli.
+var timeLayouts = map[string]string{
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC822":      time.RFC822,
+	"HTTP":        http.TimeFormat,
+	"ISO8601":     "20060102T150405Z",
+	"date":        time.DateOnly,
+}
+
+// TimeFormat formats a time in UTC with a named layout (RFC3339, RFC3339Nano, RFC1123, RFC1123Z, RFC822, HTTP,
+// ISO8601, date, unix or unixMilli) or a Go layout. The time is now, or the last arg given as a Unix time or in
+// RFC 3339 format, plus an optional offset such as +1h or -30m.
+func TimeFormat(existingHeaders map[string]string, args []string) (string, error) {
+	if !validateTimeFormat(args) {
+		return "", fmt.Errorf("error calling TimeFormat; 1 to 3 arguments are needed (layout, and optionally an offset and a time)")
+	}
+
+	t := timeNow()
+	var offset time.Duration
+	for _, arg := range args[1:] {
+		arg = argValue(existingHeaders, arg)
+		if d, err := time.ParseDuration(arg); err == nil && (strings.HasPrefix(arg, "+") || strings.HasPrefix(arg, "-")) {
+			offset = d
+			continue
+		}
+
+		parsed, err := parseTime(arg)
+		if err != nil {
+			return "", fmt.Errorf("error calling TimeFormat; %q is not an offset, Unix time or RFC 3339 time", arg)
+		}
+		t = parsed
+	}
+	t = t.Add(offset).UTC()
+
+	switch layout := args[0]; layout {
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10), nil
+	case "unixMilli":
+		return strconv.FormatInt(t.UnixMilli(), 10), nil
+	default:
+		if named, ok := timeLayouts[layout]; ok {
+			layout = named
+		}
+		return t.Format(layout), nil
+	}
+}
+
+// Validates the required number of arguments are provided (layout, and optionally an offset and a time).
+func validateTimeFormat(args []string) bool {
+	return len(args) >= 1 && len(args) <= 3
+}
+
+// Parses a Unix time in seconds or an RFC 3339 time.
+func parseTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
@@ -0,0 +1,53 @@
+package functions
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeFormat(t *testing.T) {
+	now := time.Date(2021, 4, 20, 2, 7, 55, 0, time.FixedZone("EST", -5*60*60))
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	var tests = []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"RFC3339"}, "2021-04-20T07:07:55Z"},
+		{[]string{"HTTP"}, "Tue, 20 Apr 2021 07:07:55 GMT"},
+		{[]string{"ISO8601"}, "20210420T070755Z"},
+		{[]string{"date"}, "2021-04-20"},
+		{[]string{"unix"}, "1618902475"},
+		{[]string{"unixMilli"}, "1618902475000"},
+		{[]string{"2006/01/02 15h"}, "2021/04/20 07h"},
+		{[]string{"RFC3339", "+1h"}, "2021-04-20T08:07:55Z"},
+		{[]string{"RFC3339", "-30m"}, "2021-04-20T06:37:55Z"},
+		{[]string{"RFC3339", "0"}, "1970-01-01T00:00:00Z"},
+		{[]string{"unix", "2021-04-20T02:07:55Z"}, "1618884475"},
+		{[]string{"RFC3339", "+24h", "1618884475"}, "2021-04-21T02:07:55Z"},
+		{[]string{"RFC3339", "x-previous-header"}, "2001-09-09T01:46:40Z"},
+	}
+
+	for _, tc := range tests {
+		actual, err := TimeFormat(map[string]string{"x-previous-header": "1000000000"}, tc.args)
+		if err != nil {
+			t.Errorf("TimeFormat(%v): unexpected error %v", tc.args, err)
+		}
+		if actual != tc.expected {
+			t.Errorf("TimeFormat(%v): expected %v, actual %v", tc.args, tc.expected, actual)
+		}
+	}
+
+	var errorTests = [][]string{
+		{},
+		{"RFC3339", "tomorrow"},
+		{"RFC3339", "+1h", "0", "extra"},
+	}
+
+	for _, args := range errorTests {
+		if _, err := TimeFormat(map[string]string{}, args); err == nil {
+			t.Errorf("TimeFormat(%v): expected error", args)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package functions
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// UUID returns a random (version 4) UUID.
+func UUID(existingHeaders map[string]string, args []string) (string, error) {
+	if len(args) > 0 {
+		return "", fmt.Errorf("error calling UUID; no arguments are accepted")
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error calling UUID; %w", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
@@ -0,0 +1,27 @@
+package functions
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestUUID(t *testing.T) {
+	pattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	first, err := UUID(map[string]string{}, []string{})
+	if err != nil {
+		t.Fatalf("UUID: unexpected error %v", err)
+	}
+	second, _ := UUID(map[string]string{}, []string{})
+
+	if !pattern.MatchString(first) || !pattern.MatchString(second) {
+		t.Errorf("UUID: expected version 4 UUIDs, actual %s and %s", first, second)
+	}
+	if first == second {
+		t.Errorf("UUID: expected different UUIDs, actual %s twice", first)
+	}
+
+	if _, err := UUID(map[string]string{}, []string{"4"}); err == nil {
+		t.Errorf("UUID([4]): expected error")
+	}
+}
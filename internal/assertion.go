@@ -0,0 +1,171 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"github.com/tidwall/pretty"
+)
+
+const (
+	// Limits of the actual body shown for failed assertions
+	excerptMaxLines = 20
+	excerptMaxBytes = 2048
+
+	// Maximum widths of the columns of the expected/actual view
+	expectedColumnWidth = 40
+	actualColumnWidth   = 80
+)
+
+// AssertionError is a failed assertion on a response, with what was expected
+// and the actual value. Only one of Actual, Headers or Body is set.
+type AssertionError struct {
+	Message  string
+	Expected string
+
+	// Actual is a single actual value, such as a status code
+	Actual string
+
+	// Headers are all actual response headers, with Header highlighted
+	Headers http.Header
+	Header  string
+
+	// Body is the actual response body, shown as an excerpt
+	Body        []byte
+	ContentType string
+}
+
+func (e *AssertionError) Error() string {
+	return e.Message
+}
+
+// View returns the expected and actual values side by side, with sensitive values redacted
+func (e *AssertionError) View(redactor *Redactor) string {
+	expected := strings.Split(redactor.String(e.Expected), "\n")
+
+	var actual []string
+	highlight := -1
+	switch {
+	case e.Headers != nil:
+		actual, highlight = headerLines(redactor.HTTPHeader(e.Headers), e.Header)
+	case e.Body != nil:
+		actual = bodyExcerpt(redactor.Body(e.ContentType, e.Body))
+	default:
+		actual = strings.Split(redactor.String(e.Actual), "\n")
+	}
+
+	width := len("expected")
+	for _, line := range expected {
+		width = max(width, utf8.RuneCountInString(truncate(line, expectedColumnWidth)))
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "  %s │ %s\n", pad("expected", width), "actual")
+	for i := 0; i < max(len(expected), len(actual)); i++ {
+		left, right := "", ""
+		if i < len(expected) {
+			left = truncate(expected[i], expectedColumnWidth)
+		}
+		if i < len(actual) {
+			right = truncate(actual[i], actualColumnWidth)
+		}
+
+		// Color is applied after padding, which counts printed characters only
+		switch {
+		case len(left) > 0:
+			left = color.HiGreenString(pad(left, width))
+		default:
+			left = pad(left, width)
+		}
+		switch {
+		case i == highlight:
+			right = color.HiYellowString(right)
+		case len(right) > 0:
+			right = color.HiRedString(right)
+		}
+		fmt.Fprintf(&buf, "  %s │ %s\n", left, right)
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// headerLines returns headers as sorted lines, and the index of the line of a header
+func headerLines(headers http.Header, header string) ([]string, int) {
+	if len(headers) == 0 {
+		return []string{"(no headers)"}, -1
+	}
+
+	lines := []string{}
+	highlight := -1
+	for _, name := range sortedKeys(headers) {
+		for _, value := range headers[name] {
+			if highlight < 0 && strings.EqualFold(name, header) {
+				highlight = len(lines)
+			}
+			lines = append(lines, fmt.Sprintf("%s: %s", name, value))
+		}
+	}
+	return lines, highlight
+}
+
+// bodyExcerpt returns the start of a body as lines, pretty-printing JSON
+func bodyExcerpt(body []byte) []string {
+	if len(body) == 0 {
+		return []string{"(empty body)"}
+	}
+
+	if json.Valid(body) {
+		body = pretty.PrettyOptions(body, &pretty.Options{Width: actualColumnWidth, Indent: "  "})
+	}
+
+	truncated := 0
+	if len(body) > excerptMaxBytes {
+		truncated = len(body) - excerptMaxBytes
+		body = body[:excerptMaxBytes]
+	}
+
+	text := strings.TrimRight(strings.ToValidUTF8(string(body), "�"), "\n")
+	lines := strings.Split(text, "\n")
+	if len(lines) > excerptMaxLines {
+		for _, line := range lines[excerptMaxLines:] {
+			truncated += len(line) + 1
+		}
+		lines = lines[:excerptMaxLines]
+	}
+
+	if truncated > 0 {
+		lines = append(lines, fmt.Sprintf("… (%d more bytes)", truncated))
+	}
+	return lines
+}
+
+// truncate shortens a line to a number of characters
+func truncate(s string, n int) string {
+	s = strings.ReplaceAll(s, "\t", "  ")
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
+
+func pad(s string, width int) string {
+	return s + strings.Repeat(" ", max(0, width-utf8.RuneCountInString(s)))
+}
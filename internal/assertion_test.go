@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestAssertionErrorView(t *testing.T) {
+	color.NoColor = true
+
+	var tests = []struct {
+		err      *AssertionError
+		expected string
+	}{
+		{
+			&AssertionError{Expected: "status [200 201]", Actual: "status 404"},
+			"  expected         │ actual\n" +
+				"  status [200 201] │ status 404",
+		},
+		{
+			&AssertionError{
+				Expected: "x-cache: matches hit",
+				Headers:  http.Header{"X-Cache": {"miss"}, "Content-Type": {"text/plain"}, "Set-Cookie": {"a=b"}},
+				Header:   "x-cache",
+			},
+			"  expected             │ actual\n" +
+				"  x-cache: matches hit │ Content-Type: text/plain\n" +
+				"                       │ Set-Cookie: [REDACTED]\n" +
+				"                       │ X-Cache: miss",
+		},
+		{
+			&AssertionError{
+				Expected:    "matches ok",
+				Body:        []byte(`{"status":"error","password":"x"}`),
+				ContentType: "application/json",
+			},
+			"  expected   │ actual\n" +
+				"  matches ok │ {\n" +
+				"             │   \"password\": \"[REDACTED]\",\n" +
+				"             │   \"status\": \"error\"\n" +
+				"             │ }",
+		},
+	}
+
+	redactor, err := NewRedactor(nil, nil, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range tests {
+		if actual := tc.err.View(redactor); actual != tc.expected {
+			t.Errorf("View(%+v): expected\n%s\nactual\n%s", tc.err, tc.expected, actual)
+		}
+	}
+}
+
+func TestHeaderLines(t *testing.T) {
+	lines, highlight := headerLines(http.Header{"B": {"1", "2"}, "A": {"3"}}, "b")
+	if !reflect.DeepEqual(lines, []string{"A: 3", "B: 1", "B: 2"}) || highlight != 1 {
+		t.Errorf("headerLines: unexpected result %v %d", lines, highlight)
+	}
+
+	lines, highlight = headerLines(http.Header{}, "b")
+	if !reflect.DeepEqual(lines, []string{"(no headers)"}) || highlight != -1 {
+		t.Errorf("headerLines: unexpected result %v %d", lines, highlight)
+	}
+}
+
+func TestBodyExcerpt(t *testing.T) {
+	var tests = []struct {
+		body     string
+		expected []string
+	}{
+		{"", []string{"(empty body)"}},
+		{"plain\ntext\n", []string{"plain", "text"}},
+		{`{"a":[1,2],"b":{}}`, []string{"{", `  "a": [1, 2],`, `  "b": {}`, "}"}},
+	}
+
+	for _, tc := range tests {
+		if actual := bodyExcerpt([]byte(tc.body)); !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("bodyExcerpt(%s): expected %q, actual %q", tc.body, tc.expected, actual)
+		}
+	}
+
+	// Long bodies are cut to a number of lines
+	lines := []string{}
+	for i := 0; i < 30; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	excerpt := bodyExcerpt([]byte(strings.Join(lines, "\n")))
+	if len(excerpt) != excerptMaxLines+1 || excerpt[excerptMaxLines] != "… (80 more bytes)" {
+		t.Errorf("bodyExcerpt: unexpected excerpt of long body %q", excerpt)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if actual := truncate("abcdef", 4); actual != "abc…" {
+		t.Errorf("truncate: expected abc…, actual %s", actual)
+	}
+	if actual := truncate("abc", 4); actual != "abc" {
+		t.Errorf("truncate: expected abc, actual %s", actual)
+	}
+}
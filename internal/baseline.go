@@ -0,0 +1,132 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// DefaultDiffHeaders are the response headers compared with the baseline host by default
+var DefaultDiffHeaders = []string{"content-type", "cache-control", "location", "vary"}
+
+// Diff controls how the response of a test is compared with the response of the baseline host
+type Diff struct {
+	Skip          bool     `yaml:"skip,omitempty"`
+	Headers       []string `yaml:"headers,omitempty"`
+	IgnoreHeaders []string `yaml:"ignoreHeaders,omitempty"`
+	JSON          bool     `yaml:"json,omitempty"`
+	Ignore        []string `yaml:"ignore,omitempty"`
+	Scrub         []string `yaml:"scrub,omitempty"`
+}
+
+// comparesWithBaseline reports whether the response of a test is compared with
+// the baseline host. Tests with their own host are not.
+func comparesWithBaseline(test *Test, config *Config) bool {
+	if len(config.BaselineHost) == 0 || test.Request.Host != config.Host {
+		return false
+	}
+	return test.Diff == nil || !test.Diff.Skip
+}
+
+// compareWithBaseline sends the request of a test to the baseline host, and
+// returns the differences of its response from the response of the candidate.
+// The test is prepared again for the baseline host, so that its dynamic
+// headers, authorization and signature are computed for it.
+func compareWithBaseline(ctx context.Context, test *Test, resp *http.Response, body []byte, config *Config, pageref string) []error {
+	if err := prepareTest(test, config, config.BaselineHost); err != nil {
+		return []error{fmt.Errorf("unable to prepare baseline request: %s", err)}
+	}
+
+	// A host header naming TEST_HOST names the baseline host instead
+	if test.Request.Headers["host"] == config.Host {
+		test.Request.Headers["host"] = config.BaselineHost
+	}
+
+	reqConfig, err := newRequestConfig(ctx, test, config, pageref)
+	if err != nil {
+		return []error{fmt.Errorf("unable to prepare baseline request: %s", err)}
+	}
+
+	baselineResp, baselineBody, err := SendHTTPRequest(reqConfig)
+	if err != nil {
+		return []error{fmt.Errorf("baseline request failed: %s", err)}
+	}
+
+	zap.L().Info("got baseline response",
+		zap.ByteString("body", config.Redactor.Body(baselineResp.Header.Get("Content-Type"), baselineBody)),
+		zap.String("status", baselineResp.Status),
+		zap.Any("headers", config.Redactor.HTTPHeader(baselineResp.Header)),
+	)
+
+	diff := test.Diff
+	if diff == nil {
+		diff = &Diff{}
+	}
+
+	errors := []error{}
+
+	// Status
+	if baselineResp.StatusCode != resp.StatusCode {
+		errors = append(errors, &AssertionError{
+			Message:  fmt.Sprintf("status differs from baseline %s", config.BaselineHost),
+			Expected: "status " + strconv.Itoa(baselineResp.StatusCode),
+			Actual:   "status " + strconv.Itoa(resp.StatusCode),
+		})
+	}
+
+	// Selected headers
+	headers := append(append([]string{}, config.DiffHeaders...), diff.Headers...)
+	compared := map[string]bool{}
+	for _, name := range headers {
+		name = strings.ToLower(name)
+		if compared[name] || containsFold(diff.IgnoreHeaders, name) {
+			continue
+		}
+		compared[name] = true
+
+		expected := strings.Join(baselineResp.Header.Values(name), ", ")
+		actual := strings.Join(resp.Header.Values(name), ", ")
+		if expected != actual {
+			errors = append(errors, &AssertionError{
+				Message:  fmt.Sprintf("header %s differs from baseline %s", name, config.BaselineHost),
+				Expected: fmt.Sprintf("%s: %s", name, config.Redactor.Header(name, expected)),
+				Headers:  resp.Header,
+				Header:   name,
+			})
+		}
+	}
+
+	// Normalized body
+	normalizer := &Snapshot{JSON: diff.JSON, Ignore: diff.Ignore, Scrub: diff.Scrub}
+	expected, err := normalizer.normalize(baselineBody)
+	if err != nil {
+		return append(errors, fmt.Errorf("unable to normalize baseline body: %s", err))
+	}
+	actual, err := normalizer.normalize(body)
+	if err != nil {
+		return append(errors, fmt.Errorf("unable to normalize body: %s", err))
+	}
+	if d := redactedDiff(config.Redactor, expected, actual, "baseline/"+config.BaselineHost, "candidate/"+config.Host); len(d) > 0 {
+		errors = append(errors, fmt.Errorf("response body differs from baseline %s\n%s", config.BaselineHost, d))
+	}
+
+	return errors
+}
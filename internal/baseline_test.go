@@ -0,0 +1,184 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompareWithBaseline(t *testing.T) {
+	// Responses of each host by path
+	handler := func(responses map[string][3]string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			response := responses[r.URL.Path]
+			w.Header().Set("content-type", response[1])
+			status := 200
+			fmt.Sscan(response[0], &status)
+			w.WriteHeader(status)
+			w.Write([]byte(response[2]))
+		})
+	}
+	baseline := httptest.NewServer(handler(map[string][3]string{
+		"/same":    {"200", "text/plain", "same"},
+		"/status":  {"200", "text/plain", ""},
+		"/type":    {"200", "text/html", ""},
+		"/json":    {"200", "application/json", `{"id": 1, "ts": 100}`},
+		"/changed": {"200", "text/plain", "old\nline"},
+	}))
+	defer baseline.Close()
+	candidate := httptest.NewServer(handler(map[string][3]string{
+		"/same":    {"200", "text/plain", "same"},
+		"/status":  {"404", "text/plain", ""},
+		"/type":    {"200", "text/plain", ""},
+		"/json":    {"200", "application/json", `{"ts":200,"id":1}`},
+		"/changed": {"200", "text/plain", "new\nline"},
+	}))
+	defer candidate.Close()
+
+	config := &Config{
+		Host:         strings.TrimPrefix(candidate.URL, "http://"),
+		BaselineHost: strings.TrimPrefix(baseline.URL, "http://"),
+		DiffHeaders:  DefaultDiffHeaders,
+	}
+
+	var tests = []struct {
+		path   string
+		diff   *Diff
+		errors []string
+	}{
+		{"/same", nil, nil},
+		{"/status", nil, []string{"status differs from baseline"}},
+		{"/type", nil, []string{"header content-type differs from baseline"}},
+		{"/type", &Diff{IgnoreHeaders: []string{"Content-Type"}}, nil},
+		{"/json", nil, []string{"response body differs from baseline"}},
+		{"/json", &Diff{Ignore: []string{"ts"}}, nil},
+		{"/changed", nil, []string{"-old\n+new"}},
+		{"/changed", &Diff{Scrub: []string{"old|new"}}, nil},
+		{"/changed", &Diff{Skip: true}, nil},
+	}
+
+	for _, tc := range tests {
+		test := &Test{Description: tc.path, Diff: tc.diff}
+		test.Request.Scheme = "http"
+		test.Request.Path = tc.path
+
+		result := RunTest(context.Background(), test, config)
+		if len(result.Errors) != len(tc.errors) {
+			t.Errorf("%s %+v: expected errors %v, actual %v", tc.path, tc.diff, tc.errors, result.Errors)
+			continue
+		}
+		for i, err := range result.Errors {
+			if !strings.Contains(err.Error(), tc.errors[i]) {
+				t.Errorf("%s %+v: expected error %q, actual %q", tc.path, tc.diff, tc.errors[i], err)
+			}
+		}
+	}
+
+	// Tests with their own host are not compared
+	test := &Test{Description: "own host"}
+	test.Request.Scheme = "http"
+	test.Request.Host = strings.TrimPrefix(candidate.URL, "http://")
+	test.Request.Path = "/status"
+	if comparesWithBaseline(test, &Config{Host: "example.com", BaselineHost: config.BaselineHost}) {
+		t.Errorf("comparesWithBaseline: expected false for a test with its own host")
+	}
+}
+
+func TestCompareWithBaselineRequest(t *testing.T) {
+	// Hosts of the requests received by each server
+	var baselineHosts, candidateHosts []string
+	baseline := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		baselineHosts = append(baselineHosts, r.Host)
+	}))
+	defer baseline.Close()
+	candidate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		candidateHosts = append(candidateHosts, r.Host)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer candidate.Close()
+
+	config := &Config{
+		Host:          strings.TrimPrefix(candidate.URL, "http://"),
+		BaselineHost:  strings.TrimPrefix(baseline.URL, "http://"),
+		EnableRetries: true,
+		RetryCount:    2,
+	}
+
+	var tests = []struct {
+		path          string
+		baselineHosts []string
+	}{
+		// The host header names the baseline host in the baseline request
+		{"/pass", []string{config.BaselineHost}},
+		// Failing tests are not compared, however often they are retried
+		{"/fail", nil},
+	}
+
+	for _, tc := range tests {
+		baselineHosts, candidateHosts = nil, nil
+
+		test := &Test{Description: tc.path}
+		test.Request.Scheme = "http"
+		test.Request.Path = tc.path
+		test.Request.Headers = map[string]string{"host": config.Host}
+		test.Response.StatusCodes = []int{200}
+
+		RunTest(context.Background(), test, config)
+		if fmt.Sprint(baselineHosts) != fmt.Sprint(tc.baselineHosts) {
+			t.Errorf("%s: expected baseline requests to %v, actual %v", tc.path, tc.baselineHosts, baselineHosts)
+		}
+		for _, host := range candidateHosts {
+			if host != config.Host {
+				t.Errorf("%s: expected candidate requests to %s, actual %s", tc.path, config.Host, host)
+			}
+		}
+	}
+}
+
+func TestCompareWithBaselineRedacted(t *testing.T) {
+	server := func(body string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("content-type", "application/json")
+			w.Header().Set("set-cookie", "session="+body)
+			w.Write([]byte(`{"access_token": "` + body + `"}`))
+		}))
+	}
+	baseline := server("BASELINETOKEN")
+	defer baseline.Close()
+	candidate := server("CANDIDATETOKEN")
+	defer candidate.Close()
+
+	redactor, err := NewRedactor(nil, nil, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{
+		Host:         strings.TrimPrefix(candidate.URL, "http://"),
+		BaselineHost: strings.TrimPrefix(baseline.URL, "http://"),
+		DiffHeaders:  []string{"set-cookie"},
+		Redactor:     redactor,
+	}
+
+	test := &Test{Description: "redacted"}
+	test.Request.Scheme = "http"
+	test.Request.Path = "/"
+
+	result := RunTest(context.Background(), test, config)
+	if len(result.Errors) != 2 {
+		t.Fatalf("RunTest: expected header and body differences, actual %v", result.Errors)
+	}
+	for _, err := range result.Errors {
+		message := err.Error()
+		if assertion, ok := err.(*AssertionError); ok {
+			message += assertion.Expected
+		}
+		if strings.Contains(message, "TOKEN") {
+			t.Errorf("RunTest: expected redacted difference, actual %s", message)
+		}
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 )
 
 // Config stores application configuration
@@ -30,6 +31,25 @@ type Config struct {
 	Verbosity            int
 	EnableRetries        bool
 	RetryCount           int
+	Proxy                string
+	ProxyUsername        string
+	ProxyPassword        string
+	UpdateSnapshots      bool
+	OpenAPISpec          string
+	HARFile              string
+	HARMaxBodySize       int
+	SetupFile            string
+	FunctionsFile        string
+	BaselineHost         string
+	DiffHeaders          []string
+	Redactor             *Redactor
+
+	contract  *openAPIContract
+	har       *harRecorder
+	variables *variableScope
+	tokens    *tokenCache
+	applied   bool
+	applyErr  error
 }
 
 // FromEnv returns config read from environment variables
@@ -61,6 +81,11 @@ func FromEnv() (*Config, error) {
 		enableRetries = true
 	}
 
+	updateSnapshots := false
+	if getEnv("TEST_UPDATE_SNAPSHOTS", "false") == "true" {
+		updateSnapshots = true
+	}
+
 	retryCount, err := strconv.Atoi(getEnv("DEFAULT_RETRY_COUNT", "2"))
 	if err != nil {
 		return nil, fmt.Errorf("invalid default retry count value: %s", err)
@@ -69,6 +94,35 @@ func FromEnv() (*Config, error) {
 		return nil, fmt.Errorf("invalid default retry count value: %d", retryCount)
 	}
 
+	harMaxBodySize, err := strconv.Atoi(getEnv("TEST_HAR_MAX_BODY_SIZE", "1048576"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid HAR max body size value: %s", err)
+	}
+	if harMaxBodySize < 0 {
+		return nil, fmt.Errorf("invalid HAR max body size value: %d", harMaxBodySize)
+	}
+
+	proxy := getEnv("TEST_PROXY", "")
+	if err := validateProxy(proxy); err != nil {
+		return nil, fmt.Errorf("invalid proxy value: %s", err)
+	}
+
+	// Values of environment variables marked secret are redacted wherever they appear
+	secrets := []string{os.Getenv("TEST_PROXY_PASSWORD"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN")}
+	for _, name := range SplitList(getEnv("TEST_SECRET_ENV_VARS", "")) {
+		secrets = append(secrets, os.Getenv(name))
+	}
+
+	redactor, err := NewRedactor(
+		SplitList(getEnv("TEST_REDACT_HEADERS", "")),
+		SplitList(getEnv("TEST_REDACT_FIELDS", "")),
+		getEnv("TEST_REDACT_PATTERN", ""),
+		secrets,
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Config{
 		Concurrency:          concurrency,
 		Host:                 getEnv("TEST_HOST", ""),
@@ -78,11 +132,63 @@ func FromEnv() (*Config, error) {
 		Verbosity:            verbosity,
 		EnableRetries:        enableRetries,
 		RetryCount:           retryCount,
+		Proxy:                proxy,
+		ProxyUsername:        getEnv("TEST_PROXY_USERNAME", ""),
+		ProxyPassword:        getEnv("TEST_PROXY_PASSWORD", ""),
+		UpdateSnapshots:      updateSnapshots,
+		OpenAPISpec:          getEnv("TEST_OPENAPI_SPEC", ""),
+		HARFile:              getEnv("TEST_HAR_FILE", ""),
+		HARMaxBodySize:       harMaxBodySize,
+		SetupFile:            getEnv("TEST_SETUP_FILE", ""),
+		FunctionsFile:        getEnv("TEST_FUNCTIONS_FILE", ""),
+		BaselineHost:         getEnv("TEST_BASELINE_HOST", ""),
+		DiffHeaders:          SplitList(getEnv("TEST_DIFF_HEADERS", strings.Join(DefaultDiffHeaders, ","))),
+		Redactor:             redactor,
 	}, nil
 }
 
 // ApplyConfig applies config
 func ApplyConfig(config *Config) error {
+	// Config is applied once, even if it is used for several runs. A config
+	// that failed to apply keeps failing with the same error.
+	if !config.applied {
+		config.applied = true
+		config.applyErr = applyConfig(config)
+	}
+	return config.applyErr
+}
+
+func applyConfig(config *Config) error {
+	// Tokens are shared by the tests of runs with this config
+	config.tokens = newTokenCache()
+
+	if len(config.OpenAPISpec) > 0 {
+		contract, err := loadOpenAPIContract(config.OpenAPISpec)
+		if err != nil {
+			return err
+		}
+		config.contract = contract
+	}
+
+	// Register external functions before variables can use them
+	if len(config.FunctionsFile) > 0 {
+		if err := registerExternalFunctions(config.FunctionsFile); err != nil {
+			return err
+		}
+	}
+
+	if len(config.SetupFile) > 0 {
+		variables, err := parseSetupFile(config.SetupFile)
+		if err != nil {
+			return err
+		}
+		config.variables = variables
+	}
+
+	if len(config.HARFile) > 0 {
+		config.har = newHARRecorder(config.HARMaxBodySize, config.Redactor)
+	}
+
 	if len(config.DNSOverride) > 0 {
 		if len(config.Host) < 1 {
 			return fmt.Errorf("TEST_HOST is required to use DNS override")
@@ -92,6 +198,17 @@ func ApplyConfig(config *Config) error {
 	return nil
 }
 
+// SplitList splits a comma-separated list, dropping empty values
+func SplitList(s string) []string {
+	values := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
 // Read environment variable with default values
 func getEnv(key string, defaultValue string) string {
 	val := os.Getenv(key)
@@ -15,49 +15,47 @@
 package internal
 
 import (
+	"context"
 	"sync"
+	"time"
 )
 
-// RunTests runs all tests
-func RunTests(tests []*Test, config *Config) bool {
-	sem := make(chan byte, config.Concurrency)
+// RunTests runs tests concurrently, calling report with the result of each test
+// as it finishes. Calls to report don't overlap. Tests that haven't started
+// when ctx is done are not run, and the error of ctx is returned.
+func RunTests(ctx context.Context, tests []*Test, config *Config, report func(*Test, *TestResult)) error {
+	sem := make(chan byte, max(config.Concurrency, 1))
 	mux := sync.Mutex{}
 
-	passed, failed, skipped := 0, 0, 0
+	// Compute variables once, before any test is run
+	resolveAllVariables(tests, config.variables, config.functionsTransport())
 
+schedule:
 	for _, test := range tests {
+		// Checked first, as select picks randomly between ready cases
+		if ctx.Err() != nil {
+			break
+		}
+
 		// Attempt to take a slot
-		sem <- 0
+		select {
+		case sem <- 0:
+		case <-ctx.Done():
+			break schedule
+		}
 
 		go func(t *Test) {
 			// Release a slot when done
 			defer func() { <-sem }()
 
-			maxRetries := 0
-			if config.EnableRetries {
-				maxRetries = config.RetryCount
-			}
-
-			result := RunTest(t, config.Host, maxRetries)
+			start := time.Now()
+			result := RunTest(ctx, t, config)
+			result.Duration = time.Since(start)
 
-			// Acquire lock before accessing shared variables and writing output.
+			// Acquire lock before reporting.
 			// Code in critical section should not perform network I/O.
 			mux.Lock()
-
-			if result.Skipped {
-				skipped++
-				if !config.PrintFailedTestsOnly {
-					PrintTestResult(t, result)
-				}
-			} else if len(result.Errors) > 0 {
-				failed++
-				PrintTestResult(t, result)
-			} else {
-				passed++
-				if !config.PrintFailedTestsOnly {
-					PrintTestResult(t, result)
-				}
-			}
+			report(t, result)
 			mux.Unlock()
 		}(test)
 	}
@@ -67,10 +65,16 @@ func RunTests(tests []*Test, config *Config) bool {
 		sem <- 0
 	}
 
-	PrintTestSummary(passed, failed, skipped)
+	return ctx.Err()
+}
 
-	if failed > 0 {
-		return false
+// OpenAPICoverage returns the operations of the OpenAPI specification no test
+// has matched, and the total number of operations. ok is false if no
+// specification is configured.
+func OpenAPICoverage(config *Config) (untested []string, total int, ok bool) {
+	if config.contract == nil {
+		return nil, 0, false
 	}
-	return true
+	untested, total = config.contract.coverage()
+	return untested, total, true
 }
@@ -0,0 +1,119 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// curlCommand returns a curl command that sends the same request as a processed test,
+// with sensitive values redacted
+func curlCommand(test *Test, config *Config) string {
+	redactor := config.Redactor
+	args := []string{"curl"}
+
+	// curl sends GET without a body and POST with one, unless told otherwise
+	switch test.Request.Method {
+	case "GET":
+		if len(test.Request.Body) > 0 {
+			args = append(args, "-X GET")
+		}
+	case "HEAD":
+		args = append(args, "-I")
+	default:
+		args = append(args, "-X "+test.Request.Method)
+	}
+
+	if test.SkipCertVerification {
+		args = append(args, "-k")
+	}
+
+	// TEST_DNS_OVERRIDE only applies to TEST_HOST
+	if len(config.DNSOverride) > 0 && test.Request.Host == config.Host {
+		host, port, err := net.SplitHostPort(test.Request.Host)
+		if err != nil {
+			host, port = test.Request.Host, "443"
+			if test.Request.Scheme == "http" {
+				port = "80"
+			}
+		}
+		args = append(args, "--resolve "+shellQuote(fmt.Sprintf("%s:%s:%s", host, port, config.DNSOverride)))
+	}
+
+	switch proxy := stringValue(test.Request.Proxy, config.Proxy); proxy {
+	case "":
+	case ProxyDirect:
+		args = append(args, "--noproxy "+shellQuote("*"))
+	default:
+		if u, err := url.Parse(proxy); err == nil {
+			proxy = u.Redacted()
+		}
+		args = append(args, "-x "+shellQuote(redactor.String(proxy)))
+
+		// Credentials of TEST_PROXY_USERNAME and TEST_PROXY_PASSWORD
+		if len(config.ProxyUsername) > 0 {
+			credentials := config.ProxyUsername
+			if len(config.ProxyPassword) > 0 {
+				credentials += ":" + redactedValue
+			}
+			args = append(args, "--proxy-user "+shellQuote(redactor.String(credentials)))
+		}
+	}
+
+	// Let curl sign the request, with credentials from the environment
+	if signing := test.Request.Signing; signing != nil && signing.Type == SigningAWSSigV4 {
+		args = append(args,
+			"--aws-sigv4 "+shellQuote(fmt.Sprintf("aws:amz:%s:%s", signing.Region, signing.Service)),
+			`--user "$AWS_ACCESS_KEY_ID:$AWS_SECRET_ACCESS_KEY"`,
+		)
+		if len(os.Getenv("AWS_SESSION_TOKEN")) > 0 {
+			args = append(args, `-H "x-amz-security-token: $AWS_SESSION_TOKEN"`)
+		}
+	}
+
+	for _, name := range sortedKeys(test.Request.Headers) {
+		value := redactor.Header(name, test.Request.Headers[name])
+		args = append(args, "-H "+shellQuote(name+": "+value))
+	}
+
+	if len(test.Request.Body) > 0 {
+		body := redactor.Body(test.Request.Headers["content-type"], []byte(test.Request.Body))
+		args = append(args, "--data-raw "+shellQuote(string(body)))
+	}
+
+	args = append(args, shellQuote(redactor.URL(test.Request.Scheme+"://"+test.Request.Host+test.Request.Path)))
+
+	command := strings.Join(args, " \\\n  ")
+
+	// HTTP message signatures are computed when the request is sent, for its
+	// final headers, so the command can't include them
+	if signing := test.Request.Signing; signing != nil && signing.Type == SigningHTTPSignature {
+		command = "# request is signed with HTTP Message Signatures; the Signature, Signature-Input and Content-Digest headers are left out\n" + command
+	}
+
+	return command
+}
+
+// shellQuote quotes a string for POSIX shells
+func shellQuote(s string) string {
+	if len(s) > 0 && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=@,+") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,113 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/nytimes/httptest/functions"
+)
+
+// CustomAssertion calls an assertion registered with RegisterAssertion
+type CustomAssertion struct {
+	Name string   `yaml:"name"`
+	Args []string `yaml:"args,omitempty"`
+}
+
+// AssertionFunc checks a response, returning an error if it fails
+type AssertionFunc func(response *http.Response, body []byte, args []string) error
+
+// Map of strings to custom assertions.
+var assertionMap = map[string]AssertionFunc{}
+
+// registryMux guards funcMap and assertionMap, which can be added to while tests run
+var registryMux sync.RWMutex
+
+// lookupFunction returns the dynamic header function with a name
+func lookupFunction(name string) (resolveRequestHeader, bool) {
+	registryMux.RLock()
+	defer registryMux.RUnlock()
+
+	fn, ok := funcMap[name]
+	return fn, ok
+}
+
+// lookupAssertion returns the custom assertion with a name
+func lookupAssertion(name string) (AssertionFunc, bool) {
+	registryMux.RLock()
+	defer registryMux.RUnlock()
+
+	fn, ok := assertionMap[name]
+	return fn, ok
+}
+
+// RegisterFunction adds a dynamic header function. Names can't be reused.
+// Tests already running when it is called may not see the function.
+func RegisterFunction(name string, fn func(request *functions.RequestContext, args []string) (string, error)) error {
+	if len(name) == 0 || fn == nil {
+		return fmt.Errorf("a name and a function are required")
+	}
+
+	registryMux.Lock()
+	defer registryMux.Unlock()
+
+	if _, ok := funcMap[name]; ok {
+		return fmt.Errorf("function %s is already defined", name)
+	}
+	funcMap[name] = fn
+	return nil
+}
+
+// RegisterAssertion adds an assertion that tests can call in
+// response.assertions. Names can't be reused. Tests already running when it
+// is called may not see the assertion.
+func RegisterAssertion(name string, fn AssertionFunc) error {
+	if len(name) == 0 || fn == nil {
+		return fmt.Errorf("a name and an assertion are required")
+	}
+
+	registryMux.Lock()
+	defer registryMux.Unlock()
+
+	if _, ok := assertionMap[name]; ok {
+		return fmt.Errorf("assertion %s is already defined", name)
+	}
+	assertionMap[name] = fn
+	return nil
+}
+
+func validateCustomAssertions(test *Test, response *http.Response, body []byte) []error {
+	errors := []error{}
+	for _, assertion := range test.Response.Assertions {
+		fn, ok := lookupAssertion(assertion.Name)
+		if !ok {
+			errors = append(errors, fmt.Errorf("unknown assertion %s", assertion.Name))
+			continue
+		}
+
+		err := fn(response, body, assertion.Args)
+		switch err.(type) {
+		case nil:
+		case *AssertionError:
+			// Keep the expected and actual values to show
+			errors = append(errors, err)
+		default:
+			errors = append(errors, fmt.Errorf("assertion %s failed: %w", assertion.Name, err))
+		}
+	}
+	return errors
+}
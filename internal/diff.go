@@ -0,0 +1,253 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContextLines is the number of unchanged lines shown around each change
+const diffContextLines = 3
+
+// redactedDiff returns a unified diff between a and b with sensitive values
+// redacted, or an empty string if they are equal
+func redactedDiff(redactor *Redactor, a, b, fromName, toName string) string {
+	if a == b {
+		return ""
+	}
+	if d := unifiedDiff(redactor.Text(a), redactor.Text(b), fromName, toName); len(d) > 0 {
+		return d
+	}
+	return fmt.Sprintf("--- %s\n+++ %s\nonly redacted values differ\n", fromName, toName)
+}
+
+type diffOp struct {
+	kind byte // ' ' (equal), '-' (only in a) or '+' (only in b)
+	text string
+}
+
+// unifiedDiff returns a unified diff between a and b, or an empty string if they are equal
+func unifiedDiff(a, b, fromName, toName string) string {
+	if a == b {
+		return ""
+	}
+
+	aLines, bLines := splitLines(a), splitLines(b)
+	ops, ok := diffLines(aLines, bLines)
+
+	var buffer strings.Builder
+	fmt.Fprintf(&buffer, "--- %s\n+++ %s\n", fromName, toName)
+
+	// Too many changes for a readable diff; show the first one only
+	if !ok {
+		line := 0
+		for line < len(aLines) && line < len(bLines) && aLines[line] == bLines[line] {
+			line++
+		}
+		fmt.Fprintf(&buffer, "bodies differ (%d vs %d lines), first difference at line %d:\n", len(aLines), len(bLines), line+1)
+		if line < len(aLines) {
+			fmt.Fprintf(&buffer, "-%s\n", aLines[line])
+		}
+		if line < len(bLines) {
+			fmt.Fprintf(&buffer, "+%s\n", bLines[line])
+		}
+		return buffer.String()
+	}
+
+	// Walk the edit script, emitting a hunk for each group of changes
+	for start := 0; start < len(ops); {
+		// Find next change
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		// Extend the hunk while changes are close enough to share context
+		end := start
+		for i := start; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				end = i + 1
+			} else if i-end >= 2*diffContextLines {
+				break
+			}
+		}
+
+		hunkStart := max(start-diffContextLines, 0)
+		hunkEnd := min(end+diffContextLines, len(ops))
+
+		// Line numbers of the hunk start in a and b
+		aLine, bLine := 1, 1
+		for _, op := range ops[:hunkStart] {
+			if op.kind != '+' {
+				aLine++
+			}
+			if op.kind != '-' {
+				bLine++
+			}
+		}
+
+		aCount, bCount := 0, 0
+		for _, op := range ops[hunkStart:hunkEnd] {
+			if op.kind != '+' {
+				aCount++
+			}
+			if op.kind != '-' {
+				bCount++
+			}
+		}
+
+		fmt.Fprintf(&buffer, "@@ -%s +%s @@\n", hunkRange(aLine, aCount), hunkRange(bLine, bCount))
+		for _, op := range ops[hunkStart:hunkEnd] {
+			buffer.WriteByte(op.kind)
+			buffer.WriteString(op.text)
+			buffer.WriteByte('\n')
+		}
+
+		start = hunkEnd
+	}
+
+	return buffer.String()
+}
+
+func hunkRange(line, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", line-1)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+func splitLines(s string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines computes a shortest edit script between a and b using Myers' algorithm.
+// It returns false if more than maxDiffEdits lines differ, bounding the time and
+// memory spent on large, very different bodies.
+func diffLines(a, b []string) ([]diffOp, bool) {
+	// Lines common to the start and the end are kept as they are
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	middle, ok := myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	if !ok {
+		return nil, false
+	}
+	ops = append(ops, middle...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops, true
+}
+
+// maxDiffEdits is the largest number of changed lines diffed line by line.
+// Myers' algorithm keeps a trace growing with the square of the edit distance.
+const maxDiffEdits = 1000
+
+// myersDiff computes a shortest edit script between a and b, or returns false
+// if it has more than maxDiffEdits edits
+func myersDiff(a, b []string) ([]diffOp, bool) {
+	n, m := len(a), len(b)
+	offset := n + m
+
+	// Furthest reaching x for each diagonal k, indexed by k+offset
+	v := make([]int, 2*(n+m)+2)
+	trace := [][]int{}
+
+search:
+	for d := 0; d <= n+m; d++ {
+		if d > maxDiffEdits {
+			return nil, false
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Backtrack through the trace to recover the edit script
+	ops := make([]diffOp, 0, n+m)
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		// trace[d] holds v[-d..d+1] as it was before step d
+		at := func(k int) int { return trace[d][k+d] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = at(prevK)
+		}
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, diffOp{' ', a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, diffOp{'+', b[y-1]})
+			} else {
+				ops = append(ops, diffOp{'-', a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	// Reverse into forward order
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops, true
+}
@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	var tests = []struct {
+		a        string
+		b        string
+		expected string
+	}{
+		{
+			"a\nb\nc\n",
+			"a\nb\nc\n",
+			"",
+		},
+		{
+			"a\nb\nc\n",
+			"a\nx\nc\n",
+			"--- a\n+++ b\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n",
+		},
+		{
+			"",
+			"a\n",
+			"--- a\n+++ b\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n",
+			"--- a\n+++ b\n@@ -10,3 +10,4 @@\n 10\n 11\n 12\n+13\n",
+		},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			"0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			"--- a\n+++ b\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n@@ -9,4 +10,3 @@\n 9\n 10\n 11\n-12\n",
+		},
+	}
+
+	for _, tc := range tests {
+		actual := unifiedDiff(tc.a, tc.b, "a", "b")
+		if actual != tc.expected {
+			t.Errorf("unifiedDiff(%q, %q): expected %q, actual %q", tc.a, tc.b, tc.expected, actual)
+		}
+	}
+}
+
+func TestUnifiedDiffLarge(t *testing.T) {
+	var a, b strings.Builder
+	for i := 0; i < 10000; i++ {
+		fmt.Fprintf(&a, "a%d\n", i)
+		fmt.Fprintf(&b, "b%d\n", i)
+	}
+
+	// Too many changes are summarized
+	expected := "--- a\n+++ b\nbodies differ (10000 vs 10000 lines), first difference at line 1:\n-a0\n+b0\n"
+	if actual := unifiedDiff(a.String(), b.String(), "a", "b"); actual != expected {
+		t.Errorf("unifiedDiff: expected %q, actual %q", expected, actual)
+	}
+
+	// Few changes in large bodies are diffed
+	changed := strings.Replace(a.String(), "a5000\n", "x\n", 1)
+	expected = "--- a\n+++ b\n@@ -4998,7 +4998,7 @@\n a4997\n a4998\n a4999\n-a5000\n+x\n a5001\n a5002\n a5003\n"
+	if actual := unifiedDiff(a.String(), changed, "a", "b"); actual != expected {
+		t.Errorf("unifiedDiff: expected %q, actual %q", expected, actual)
+	}
+}
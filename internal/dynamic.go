@@ -16,21 +16,22 @@ package internal
 
 import (
 	"fmt"
+	"net/url"
 
 	"github.com/nytimes/httptest/functions"
 )
 
-// ProcessDynamicHeaders creates headers based on the function and adds them to the map of all headers.
-func ProcessDynamicHeaders(dynamicHeaders []DynamicHeader, allHeaders map[string]string) error {
+// ProcessDynamicHeaders creates headers based on the function and adds them to the headers of the request.
+func ProcessDynamicHeaders(dynamicHeaders []DynamicHeader, request *functions.RequestContext) error {
 	for _, dynamicHeader := range dynamicHeaders {
-		if _, present := allHeaders[dynamicHeader.Name]; present {
+		if _, present := request.Headers[dynamicHeader.Name]; present {
 			return fmt.Errorf("cannot process dynamic header %s; a header with that name is already defined", dynamicHeader.Name)
 		}
-		if fn, ok := funcMap[dynamicHeader.Function]; !ok {
+		if fn, ok := lookupFunction(dynamicHeader.Function); !ok {
 			return fmt.Errorf("unknown function %s", dynamicHeader.Function)
 		} else {
 			var err error
-			allHeaders[dynamicHeader.Name], err = fn(allHeaders, dynamicHeader.Args)
+			request.Headers[dynamicHeader.Name], err = fn(request, dynamicHeader.Args)
 			if err != nil {
 				return err
 			}
@@ -39,13 +40,69 @@ func ProcessDynamicHeaders(dynamicHeaders []DynamicHeader, allHeaders map[string
 	return nil
 }
 
-// Generic signature for any function that can resolve a dynamic header value.
+// Generic signature for any function that can resolve a dynamic header value from the existing headers.
 type resolveHeader func(existingHeaders map[string]string, args []string) (string, error)
 
+// Signature for functions that resolve a dynamic header value from the whole request.
+type resolveRequestHeader func(request *functions.RequestContext, args []string) (string, error)
+
+// withHeaders adapts a function that only needs the existing headers.
+func withHeaders(fn resolveHeader) resolveRequestHeader {
+	return func(request *functions.RequestContext, args []string) (string, error) {
+		return fn(request.Headers, args)
+	}
+}
+
 // Map of strings to dynamic header functions.
-var funcMap = map[string]resolveHeader{
-	"now":                  functions.Now,
-	"signStringRS256PKCS8": functions.SignStringRS256PKCS8,
-	"postFormURLEncoded":   functions.PostFormURLEncoded,
-	"concat":               functions.Concat,
+var funcMap = map[string]resolveRequestHeader{
+	"now":                  withHeaders(functions.Now),
+	"signStringRS256PKCS8": withHeaders(functions.SignStringRS256PKCS8),
+	"postFormURLEncoded":   functions.PostFormURLEncodedRequest,
+	"concat":               withHeaders(functions.Concat),
+	"hmacSHA256":           withHeaders(functions.HMACSHA256),
+	"hmacSHA512":           withHeaders(functions.HMACSHA512),
+	"hmacSHA1":             withHeaders(functions.HMACSHA1),
+	"signJWT":              withHeaders(functions.SignJWT),
+	"bodyDigest":           functions.BodyDigest,
+	"uuid":                 withHeaders(functions.UUID),
+	"randomString":         withHeaders(functions.RandomString),
+	"randomInt":            withHeaders(functions.RandomInt),
+	"base64Encode":         withHeaders(functions.Base64Encode),
+	"base64Decode":         withHeaders(functions.Base64Decode),
+	"urlEncode":            withHeaders(functions.URLEncode),
+	"jsonEscape":           withHeaders(functions.JSONEscape),
+	"sha256Hex":            withHeaders(functions.SHA256Hex),
+	"md5":                  withHeaders(functions.MD5),
+	"lower":                withHeaders(functions.Lower),
+	"upper":                withHeaders(functions.Upper),
+	"env":                  withHeaders(functions.Env),
+	"readFile":             withHeaders(functions.ReadFile),
+	"timeFormat":           withHeaders(functions.TimeFormat),
+}
+
+// newRequestContext describes the request of a test for dynamic header functions
+func newRequestContext(test *Test) (*functions.RequestContext, error) {
+	u, err := url.Parse(test.Request.Path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request path: %s", err)
+	}
+
+	if test.Request.Headers == nil {
+		test.Request.Headers = map[string]string{}
+	}
+
+	return &functions.RequestContext{
+		Method:  test.Request.Method,
+		Scheme:  test.Request.Scheme,
+		Host:    test.Request.Host,
+		Path:    u.Path,
+		Query:   u.Query(),
+		Body:    []byte(test.Request.Body),
+		Headers: test.Request.Headers,
+		Test: functions.TestInfo{
+			Filename:    test.Filename,
+			Description: test.Description,
+		},
+		Transport: test.transport,
+	}, nil
 }
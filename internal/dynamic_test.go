@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestProcessDynamicHeaders(t *testing.T) {
+	test := &Test{Filename: "tests.yml", Description: "digest"}
+	test.Request.Method = "POST"
+	test.Request.Path = "/items?a=1"
+	test.Request.Body = `{"hello": "world"}`
+	test.Request.DynamicHeaders = []DynamicHeader{
+		{Name: "x-digest", Function: "bodyDigest", Args: []string{"sha256", "base64"}},
+		{Name: "digest", Function: "concat", Args: []string{"SHA-256=", "x-digest"}},
+	}
+
+	request, err := newRequestContext(test)
+	if err != nil {
+		t.Fatalf("newRequestContext: unexpected error %v", err)
+	}
+	if request.Path != "/items" || request.Query.Get("a") != "1" || request.Test.Description != "digest" {
+		t.Errorf("newRequestContext: unexpected request %+v", request)
+	}
+
+	if err := ProcessDynamicHeaders(test.Request.DynamicHeaders, request); err != nil {
+		t.Fatalf("ProcessDynamicHeaders: unexpected error %v", err)
+	}
+
+	expected := "SHA-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE="
+	if actual := test.Request.Headers["digest"]; actual != expected {
+		t.Errorf("ProcessDynamicHeaders: expected %s, actual %s", expected, actual)
+	}
+
+	// Headers can't be defined twice
+	if err := ProcessDynamicHeaders(test.Request.DynamicHeaders, request); err == nil {
+		t.Errorf("ProcessDynamicHeaders: expected error for existing header")
+	}
+	if err := ProcessDynamicHeaders([]DynamicHeader{{Name: "x", Function: "unknown"}}, request); err == nil {
+		t.Errorf("ProcessDynamicHeaders: expected error for unknown function")
+	}
+}
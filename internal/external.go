@@ -0,0 +1,205 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/drone/envsubst"
+	"github.com/nytimes/httptest/functions"
+	"gopkg.in/yaml.v2"
+)
+
+// Timeout of external functions that don't set one
+const defaultExternalFunctionTimeout = 10 * time.Second
+
+// FunctionsFile declares external functions
+type FunctionsFile struct {
+	Functions []ExternalFunction `yaml:"functions"`
+}
+
+// ExternalFunction is a dynamic header function run as a subprocess
+type ExternalFunction struct {
+	Name    string   `yaml:"name"`
+	Command []string `yaml:"command"`
+	Timeout string   `yaml:"timeout,omitempty"`
+}
+
+// externalFunctionInput is written to the stdin of an external function
+type externalFunctionInput struct {
+	Function string                  `json:"function"`
+	Args     []string                `json:"args"`
+	Request  externalFunctionRequest `json:"request"`
+}
+
+type externalFunctionRequest struct {
+	Method  string              `json:"method"`
+	Scheme  string              `json:"scheme"`
+	Host    string              `json:"host"`
+	Path    string              `json:"path"`
+	Query   map[string][]string `json:"query"`
+	Body    string              `json:"body"`
+	Headers map[string]string   `json:"headers"`
+	Test    struct {
+		Filename    string `json:"filename"`
+		Description string `json:"description"`
+	} `json:"test"`
+}
+
+// resolver returns the dynamic header function that runs the command. Relative
+// command paths are relative to dir.
+func (f *ExternalFunction) resolver(dir string) (resolveRequestHeader, error) {
+	if len(f.Name) == 0 {
+		return nil, fmt.Errorf("name is required")
+	}
+	if len(f.Command) == 0 {
+		return nil, fmt.Errorf("command of %s is required", f.Name)
+	}
+
+	timeout := defaultExternalFunctionTimeout
+	if len(f.Timeout) > 0 {
+		var err error
+		if timeout, err = time.ParseDuration(f.Timeout); err != nil {
+			return nil, fmt.Errorf("invalid timeout of %s: %s", f.Name, err)
+		}
+	}
+
+	command := append([]string{}, f.Command...)
+	if strings.ContainsRune(command[0], filepath.Separator) && !filepath.IsAbs(command[0]) {
+		command[0] = filepath.Join(dir, command[0])
+	}
+
+	name := f.Name
+	return func(request *functions.RequestContext, args []string) (string, error) {
+		return runExternalFunction(name, command, timeout, request, args)
+	}, nil
+}
+
+// runExternalFunction writes the args and request as JSON to the stdin of the
+// command, and returns its stdout without the trailing newline
+func runExternalFunction(name string, command []string, timeout time.Duration, request *functions.RequestContext, args []string) (string, error) {
+	input := externalFunctionInput{Function: name, Args: args}
+	if args == nil {
+		input.Args = []string{}
+	}
+	input.Request = externalFunctionRequest{
+		Method:  request.Method,
+		Scheme:  request.Scheme,
+		Host:    request.Host,
+		Path:    request.Path,
+		Query:   request.Query,
+		Body:    string(request.Body),
+		Headers: request.Headers,
+	}
+	input.Request.Test.Filename = request.Test.Filename
+	input.Request.Test.Description = request.Test.Description
+
+	data, err := json.Marshal(input)
+	if err != nil {
+		return "", fmt.Errorf("error calling %s; %s", name, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	// Don't wait for subprocesses of a killed command that keep its output open
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", fmt.Errorf("error calling %s; timed out after %s", name, timeout)
+		}
+		if message := strings.TrimSpace(stderr.String()); len(message) > 0 {
+			return "", fmt.Errorf("error calling %s; %s: %s", name, err, message)
+		}
+		return "", fmt.Errorf("error calling %s; %s", name, err)
+	}
+
+	return strings.TrimSuffix(strings.TrimSuffix(stdout.String(), "\n"), "\r"), nil
+}
+
+// Functions files registered so far, by absolute path
+var externalFunctionFiles = map[string]bool{}
+
+// registerExternalFunctions adds the functions of a functions file to funcMap.
+// Nothing is added if a function is invalid or its name is already defined.
+// A file is registered once, even if several configs use it.
+func registerExternalFunctions(filePath string) error {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("ioutil: %v", err)
+	}
+
+	// Environment variable substitution
+	yamlString, err := envsubst.EvalEnv(string(data))
+	if err != nil {
+		return fmt.Errorf("unable to parse file %s: %v", filePath, err)
+	}
+
+	ff := FunctionsFile{}
+	if err := yaml.Unmarshal([]byte(yamlString), &ff); err != nil {
+		return fmt.Errorf("unable to parse file %s: %v", filePath, err)
+	}
+
+	resolvers := map[string]resolveRequestHeader{}
+	for _, f := range ff.Functions {
+		fn, err := f.resolver(filepath.Dir(filePath))
+		if err != nil {
+			return fmt.Errorf("invalid function in %s: %s", filePath, err)
+		}
+		if _, ok := resolvers[f.Name]; ok {
+			return fmt.Errorf("invalid function in %s: %s is defined twice", filePath, f.Name)
+		}
+		resolvers[f.Name] = fn
+	}
+
+	registryMux.Lock()
+	defer registryMux.Unlock()
+
+	if externalFunctionFiles[absPath] {
+		return nil
+	}
+	for name := range resolvers {
+		if _, ok := funcMap[name]; ok {
+			return fmt.Errorf("invalid function in %s: %s is already defined", filePath, name)
+		}
+	}
+	for name, fn := range resolvers {
+		funcMap[name] = fn
+	}
+	externalFunctionFiles[absPath] = true
+
+	return nil
+}
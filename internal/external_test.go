@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nytimes/httptest/functions"
+)
+
+func TestExternalFunctions(t *testing.T) {
+	dir := t.TempDir()
+	script := `#!/bin/sh
+input=$(cat)
+case "$1" in
+  echo) printf '%s\n' "$input" ;;
+  fail) echo "bad key" >&2; exit 3 ;;
+  slow) exec sleep 5 ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "fn.sh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	functionsFile := `
+functions:
+  - name: testEcho
+    command: ['./fn.sh', 'echo']
+  - name: testFail
+    command: ['./fn.sh', 'fail']
+  - name: testSlow
+    command: ['./fn.sh', 'slow']
+    timeout: 100ms
+`
+	path := filepath.Join(dir, "functions.yml")
+	if err := os.WriteFile(path, []byte(functionsFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := registerExternalFunctions(path); err != nil {
+		t.Fatalf("registerExternalFunctions: unexpected error %v", err)
+	}
+	defer func() {
+		delete(funcMap, "testEcho")
+		delete(funcMap, "testFail")
+		delete(funcMap, "testSlow")
+	}()
+
+	request := &functions.RequestContext{
+		Method:  "POST",
+		Path:    "/orders",
+		Body:    []byte(`{"id":1}`),
+		Headers: map[string]string{"x-a": "1"},
+		Test:    functions.TestInfo{Filename: "orders.yml"},
+	}
+	actual, err := funcMap["testEcho"](request, []string{"key", "value"})
+	if err != nil {
+		t.Fatalf("testEcho: unexpected error %v", err)
+	}
+	expected := `{"function":"testEcho","args":["key","value"],"request":{"method":"POST","scheme":"","host":"","path":"/orders","query":null,"body":"{\"id\":1}","headers":{"x-a":"1"},"test":{"filename":"orders.yml","description":""}}}`
+	if actual != expected {
+		t.Errorf("testEcho: expected %s, actual %s", expected, actual)
+	}
+
+	if _, err := funcMap["testFail"](request, nil); err == nil || !strings.Contains(err.Error(), "bad key") {
+		t.Errorf("testFail: expected error with stderr, actual %v", err)
+	}
+	if _, err := funcMap["testSlow"](request, nil); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("testSlow: expected timeout, actual %v", err)
+	}
+
+	// A file is registered once
+	if err := registerExternalFunctions(path); err != nil {
+		t.Errorf("registerExternalFunctions: unexpected error registering the file again %v", err)
+	}
+}
+
+func TestRegisterExternalFunctionsErrors(t *testing.T) {
+	var tests = []struct {
+		functionsFile string
+		err           string
+	}{
+		{"functions:\n  - name: testFirst\n    command: ['true']\n  - name: testInvalid\n", "command of testInvalid is required"},
+		{"functions:\n  - name: testFirst\n    command: ['true']\n  - name: concat\n    command: ['true']\n", "concat is already defined"},
+		{"functions:\n  - name: testFirst\n    command: ['true']\n  - name: testFirst\n    command: ['false']\n", "testFirst is defined twice"},
+	}
+
+	for i, tc := range tests {
+		path := filepath.Join(t.TempDir(), "functions.yml")
+		if err := os.WriteFile(path, []byte(tc.functionsFile), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		err := registerExternalFunctions(path)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("registerExternalFunctions %d: expected error %q, actual %v", i, tc.err, err)
+		}
+
+		// Nothing of an invalid file is registered
+		if _, ok := lookupFunction("testFirst"); ok {
+			t.Errorf("registerExternalFunctions %d: testFirst registered from an invalid file", i)
+			delete(funcMap, "testFirst")
+		}
+	}
+}
+
+func TestExternalFunctionResolver(t *testing.T) {
+	var tests = []struct {
+		function ExternalFunction
+		valid    bool
+	}{
+		{ExternalFunction{Name: "sign", Command: []string{"sign"}}, true},
+		{ExternalFunction{Name: "sign", Command: []string{"sign"}, Timeout: "2s"}, true},
+		{ExternalFunction{Command: []string{"sign"}}, false},
+		{ExternalFunction{Name: "sign"}, false},
+		{ExternalFunction{Name: "sign", Command: []string{"sign"}, Timeout: "2"}, false},
+	}
+
+	for _, tc := range tests {
+		if _, err := tc.function.resolver("."); (err == nil) != tc.valid {
+			t.Errorf("resolver(%+v): expected valid %t, actual error %v", tc.function, tc.valid, err)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"gopkg.in/yaml.v2"
+)
+
+// GenerateFromOpenAPI creates a test for each operation in an OpenAPI 3 specification
+func GenerateFromOpenAPI(path string) (*TestFile, error) {
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = true
+
+	doc, err := loader.LoadFromFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load OpenAPI spec %s: %s", path, err)
+	}
+
+	// Prefix paths with the base path of the first server
+	basePath := ""
+	if len(doc.Servers) > 0 {
+		if u, err := url.Parse(doc.Servers[0].URL); err == nil {
+			basePath = strings.TrimSuffix(u.Path, "/")
+		}
+	}
+
+	tf := &TestFile{}
+	for _, path := range sortedKeys(doc.Paths.Map()) {
+		pathItem := doc.Paths.Value(path)
+
+		methods := []string{}
+		for method := range pathItem.Operations() {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+
+		for _, method := range methods {
+			operation := pathItem.GetOperation(method)
+
+			// Operation parameters override path item parameters of the same name and location
+			parameters := map[string]*openapi3.Parameter{}
+			for _, params := range []openapi3.Parameters{pathItem.Parameters, operation.Parameters} {
+				for _, ref := range params {
+					if ref.Value != nil {
+						parameters[ref.Value.In+":"+ref.Value.Name] = ref.Value
+					}
+				}
+			}
+
+			tf.Tests = append(tf.Tests, generateTest(basePath, path, method, operation, parameters))
+		}
+	}
+
+	return tf, nil
+}
+
+func generateTest(basePath, path, method string, operation *openapi3.Operation, parameters map[string]*openapi3.Parameter) *Test {
+	test := &Test{}
+	test.Description = stringValue(operation.Summary, stringValue(operation.OperationID, method+" "+path))
+	test.Request.Method = method
+	test.Request.Headers = map[string]string{}
+
+	// Parameters
+	query := url.Values{}
+	for _, name := range sortedKeys(parameters) {
+		param := parameters[name]
+		value, ok := parameterExample(param)
+		if !ok && !param.Required {
+			continue
+		}
+
+		switch param.In {
+		case openapi3.ParameterInPath:
+			path = strings.ReplaceAll(path, "{"+param.Name+"}", url.PathEscape(value))
+		case openapi3.ParameterInQuery:
+			query.Add(param.Name, value)
+		case openapi3.ParameterInHeader:
+			test.Request.Headers[strings.ToLower(param.Name)] = value
+		}
+	}
+
+	test.Request.Path = basePath + path
+	if len(query) > 0 {
+		test.Request.Path += "?" + query.Encode()
+	}
+
+	// Request body, preferring JSON
+	if operation.RequestBody != nil && operation.RequestBody.Value != nil {
+		if contentType, mediaType := preferredMediaType(operation.RequestBody.Value.Content); mediaType != nil {
+			if body, ok := mediaTypeExample(mediaType); ok {
+				test.Request.Headers["content-type"] = contentType
+				test.Request.Body = body
+			}
+		}
+	}
+
+	// Declared success codes, and required headers of their responses
+	test.Response.Headers.Patterns = map[string]string{}
+	if operation.Responses != nil {
+		for code, ref := range operation.Responses.Map() {
+			status, err := strconv.Atoi(code)
+			if err != nil || status < 200 || status > 299 {
+				continue
+			}
+			test.Response.StatusCodes = append(test.Response.StatusCodes, status)
+
+			if ref.Value == nil {
+				continue
+			}
+			for name, header := range ref.Value.Headers {
+				if header.Value != nil && header.Value.Required {
+					test.Response.Headers.Patterns[strings.ToLower(name)] = ".+"
+				}
+			}
+		}
+	}
+	sort.Ints(test.Response.StatusCodes)
+
+	return test
+}
+
+// parameterExample returns an example value for a parameter, or a placeholder based on its type
+func parameterExample(param *openapi3.Parameter) (string, bool) {
+	if param.Example != nil {
+		return exampleString(param.Example), true
+	}
+	for _, name := range sortedKeys(param.Examples) {
+		if example := param.Examples[name]; example.Value != nil && example.Value.Value != nil {
+			return exampleString(example.Value.Value), true
+		}
+	}
+
+	if param.Schema != nil && param.Schema.Value != nil {
+		schema := param.Schema.Value
+		switch {
+		case schema.Example != nil:
+			return exampleString(schema.Example), true
+		case schema.Default != nil:
+			return exampleString(schema.Default), true
+		case len(schema.Enum) > 0:
+			return exampleString(schema.Enum[0]), true
+		case schema.Type.Is(openapi3.TypeInteger), schema.Type.Is(openapi3.TypeNumber):
+			return "1", false
+		case schema.Type.Is(openapi3.TypeBoolean):
+			return "true", false
+		}
+	}
+
+	return param.Name, false
+}
+
+// mediaTypeExample returns an example body for a media type
+func mediaTypeExample(mediaType *openapi3.MediaType) (string, bool) {
+	if mediaType.Example != nil {
+		return exampleBody(mediaType.Example), true
+	}
+	for _, name := range sortedKeys(mediaType.Examples) {
+		if example := mediaType.Examples[name]; example.Value != nil && example.Value.Value != nil {
+			return exampleBody(example.Value.Value), true
+		}
+	}
+	if mediaType.Schema != nil && mediaType.Schema.Value != nil && mediaType.Schema.Value.Example != nil {
+		return exampleBody(mediaType.Schema.Value.Example), true
+	}
+	return "", false
+}
+
+func preferredMediaType(content openapi3.Content) (string, *openapi3.MediaType) {
+	if mediaType := content.Get("application/json"); mediaType != nil {
+		return "application/json", mediaType
+	}
+	for _, contentType := range sortedKeys(content) {
+		return contentType, content[contentType]
+	}
+	return "", nil
+}
+
+func exampleString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
+
+func exampleBody(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// MarshalTestFile encodes a test file as YAML
+func MarshalTestFile(tf *TestFile) ([]byte, error) {
+	return yaml.Marshal(tf)
+}
@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateFromOpenAPI(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spec.yml")
+	if err := os.WriteFile(path, []byte(testOpenAPISpec), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tf, err := GenerateFromOpenAPI(path)
+	if err != nil {
+		t.Fatalf("GenerateFromOpenAPI: unexpected error %v", err)
+	}
+
+	actual, err := MarshalTestFile(tf)
+	if err != nil {
+		t.Fatalf("MarshalTestFile: unexpected error %v", err)
+	}
+
+	expected := `tests:
+- description: GET /status
+  request:
+    method: GET
+    path: /v1/status
+  response:
+    statusCodes:
+    - 200
+- description: DELETE /users/{id}
+  request:
+    method: DELETE
+    path: /v1/users/1
+  response:
+    statusCodes:
+    - 204
+- description: GET /users/{id}
+  request:
+    method: GET
+    path: /v1/users/1
+  response:
+    statusCodes:
+    - 200
+    headers:
+      patterns:
+        x-request-id: .+
+`
+	if string(actual) != expected {
+		t.Errorf("GenerateFromOpenAPI: expected\n%s\nactual\n%s", expected, actual)
+	}
+}
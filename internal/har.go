@@ -0,0 +1,477 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// harLog is the HAR 1.2 log written by the recorder
+// http://www.softwareishard.com/blog/har-12-spec/
+type harLog struct {
+	Version string      `json:"version"`
+	Creator harCreator  `json:"creator"`
+	Pages   []*harPage  `json:"pages"`
+	Entries []*harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harPage struct {
+	StartedDateTime time.Time `json:"startedDateTime"`
+	ID              string    `json:"id"`
+	Title           string    `json:"title"`
+	PageTimings     struct{}  `json:"pageTimings"`
+}
+
+type harEntry struct {
+	Pageref         string      `json:"pageref,omitempty"`
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Connection      string      `json:"connection,omitempty"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harCookie    `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harCookie    `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// harTimings are in milliseconds, with -1 for phases that did not happen
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// WriteHAR writes the requests sent by the tests to the HAR file, if one is configured
+func WriteHAR(config *Config) error {
+	if config.har == nil {
+		return nil
+	}
+	return config.har.write(config.HARFile)
+}
+
+// functionsTransport returns the transport of requests that dynamic header
+// functions send outside of tests, such as for variables, recorded without a page
+func (c *Config) functionsTransport() http.RoundTripper {
+	if c.har == nil {
+		return nil
+	}
+	return c.har.transport(http.DefaultTransport, "")
+}
+
+// harRecorder collects the requests sent during a test run
+type harRecorder struct {
+	maxBodySize int
+	redactor    *Redactor
+	mux         sync.Mutex
+	pages       []*harPage
+	entries     []*harEntry
+}
+
+func newHARRecorder(maxBodySize int, redactor *Redactor) *harRecorder {
+	return &harRecorder{maxBodySize: maxBodySize, redactor: redactor}
+}
+
+// page adds a page for a test and returns its ID
+func (r *harRecorder) page(title string) string {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	page := &harPage{
+		StartedDateTime: time.Now(),
+		ID:              fmt.Sprintf("page_%d", len(r.pages)+1),
+		Title:           title,
+	}
+	r.pages = append(r.pages, page)
+	return page.ID
+}
+
+// transport wraps a transport to record every request sent through it
+func (r *harRecorder) transport(next http.RoundTripper, pageref string) http.RoundTripper {
+	return &harTransport{recorder: r, next: next, pageref: pageref}
+}
+
+// write writes the recorded requests to a HAR file
+func (r *harRecorder) write(path string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	// Tests run concurrently, so order by start time
+	sort.SliceStable(r.pages, func(i, j int) bool { return r.pages[i].StartedDateTime.Before(r.pages[j].StartedDateTime) })
+	sort.SliceStable(r.entries, func(i, j int) bool {
+		return r.entries[i].StartedDateTime.Before(r.entries[j].StartedDateTime)
+	})
+
+	data, err := json.MarshalIndent(map[string]*harLog{
+		"log": {
+			Version: "1.2",
+			Creator: harCreator{Name: "httptest", Version: "1.0"},
+			Pages:   append([]*harPage{}, r.pages...),
+			Entries: append([]*harEntry{}, r.entries...),
+		},
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// body returns the redacted body as HAR text, capped to the maximum body size
+func (r *harRecorder) body(contentType string, data []byte) (text, encoding, comment string) {
+	data = r.redactor.Body(contentType, data)
+	if len(data) > r.maxBodySize {
+		comment = fmt.Sprintf("truncated to %d of %d bytes", r.maxBodySize, len(data))
+		data = data[:r.maxBodySize]
+
+		// Don't leave half of a character at the end
+		for i := 0; i < utf8.UTFMax && len(data) > 0 && !utf8.Valid(data); i++ {
+			data = data[:len(data)-1]
+		}
+	}
+
+	if !utf8.Valid(data) {
+		return base64.StdEncoding.EncodeToString(data), "base64", comment
+	}
+	return string(data), "", comment
+}
+
+func (r *harRecorder) add(entry *harEntry) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.entries = append(r.entries, entry)
+}
+
+type harTransport struct {
+	recorder *harRecorder
+	next     http.RoundTripper
+	pageref  string
+}
+
+// RoundTrip sends the request, reading both bodies so they can be recorded
+func (t *harTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := &harTrace{}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace.clientTrace()))
+
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		reqBody = data
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+
+	var respBody []byte
+	if err == nil {
+		respBody, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	}
+	end := time.Now()
+
+	t.recorder.add(t.entry(req, reqBody, resp, respBody, err, trace, start, end))
+
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (t *harTransport) entry(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, err error, trace *harTrace, start, end time.Time) *harEntry {
+	redactor := t.recorder.redactor
+	entry := &harEntry{
+		Pageref:         t.pageref,
+		StartedDateTime: start,
+		Time:            milliseconds(start, end),
+		Timings:         trace.timings(start, end),
+		ServerIPAddress: trace.serverIP(),
+	}
+
+	// Request
+	host := req.Host
+	if len(host) == 0 {
+		host = req.URL.Host
+	}
+	entry.Request = harRequest{
+		Method:      req.Method,
+		URL:         redactor.URL(req.URL.String()),
+		HTTPVersion: req.Proto,
+		Cookies:     []harCookie{},
+		Headers:     append([]harNameValue{{Name: "Host", Value: host}}, harHeaders(redactor.HTTPHeader(req.Header))...),
+		QueryString: []harNameValue{},
+		HeadersSize: -1,
+		BodySize:    len(reqBody),
+	}
+	for _, cookie := range req.Cookies() {
+		entry.Request.Cookies = append(entry.Request.Cookies, harCookie{Name: cookie.Name, Value: redactor.Header("cookie", cookie.Value)})
+	}
+	query := req.URL.Query()
+	for _, name := range sortedKeys(query) {
+		for _, value := range query[name] {
+			entry.Request.QueryString = append(entry.Request.QueryString, harNameValue{Name: name, Value: queryValue(redactor, name, value)})
+		}
+	}
+	if reqBody != nil {
+		text, _, comment := t.recorder.body(req.Header.Get("Content-Type"), reqBody)
+		entry.Request.PostData = &harPostData{
+			MimeType: req.Header.Get("Content-Type"),
+			Text:     text,
+			Comment:  comment,
+		}
+	}
+
+	// Response, left empty if the request failed
+	if resp == nil {
+		entry.Response = harResponse{Cookies: []harCookie{}, Headers: []harNameValue{}, HeadersSize: -1, BodySize: -1}
+		entry.Comment = redactor.String(err.Error())
+		return entry
+	}
+
+	entry.Response = harResponse{
+		Status:      resp.StatusCode,
+		StatusText:  strings.TrimSpace(strings.TrimPrefix(resp.Status, fmt.Sprintf("%d", resp.StatusCode))),
+		HTTPVersion: resp.Proto,
+		Cookies:     []harCookie{},
+		Headers:     harHeaders(redactor.HTTPHeader(resp.Header)),
+		RedirectURL: redactor.URL(resp.Header.Get("Location")),
+		HeadersSize: -1,
+		BodySize:    len(respBody),
+	}
+	for _, cookie := range resp.Cookies() {
+		entry.Response.Cookies = append(entry.Response.Cookies, harCookie{
+			Name:     cookie.Name,
+			Value:    redactor.Header("set-cookie", cookie.Value),
+			Path:     cookie.Path,
+			Domain:   cookie.Domain,
+			HTTPOnly: cookie.HttpOnly,
+			Secure:   cookie.Secure,
+		})
+	}
+	text, encoding, comment := t.recorder.body(resp.Header.Get("Content-Type"), respBody)
+	entry.Response.Content = harContent{
+		Size:     len(respBody),
+		MimeType: resp.Header.Get("Content-Type"),
+		Text:     text,
+		Encoding: encoding,
+		Comment:  comment,
+	}
+	if err != nil {
+		entry.Comment = redactor.String(err.Error())
+	}
+
+	// HTTP version of the request is only known once sent
+	entry.Request.HTTPVersion = resp.Proto
+
+	return entry
+}
+
+func queryValue(redactor *Redactor, name, value string) string {
+	if redactor.Field(name) {
+		return redactedValue
+	}
+	return redactor.String(value)
+}
+
+func harHeaders(header http.Header) []harNameValue {
+	headers := []harNameValue{}
+	for _, name := range sortedKeys(header) {
+		for _, value := range header[name] {
+			headers = append(headers, harNameValue{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+// harTrace records when each phase of a request happened
+type harTrace struct {
+	mux          sync.Mutex
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	gotConn      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	remoteAddr   string
+}
+
+func (t *harTrace) set(field *time.Time) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if field.IsZero() {
+		*field = time.Now()
+	}
+}
+
+func (t *harTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { t.set(&t.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { t.set(&t.dnsDone) },
+		ConnectStart:      func(string, string) { t.set(&t.connectStart) },
+		ConnectDone:       func(string, string, error) { t.set(&t.connectDone) },
+		TLSHandshakeStart: func() { t.set(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { t.set(&t.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.set(&t.gotConn)
+			t.mux.Lock()
+			defer t.mux.Unlock()
+			if info.Conn != nil {
+				t.remoteAddr = info.Conn.RemoteAddr().String()
+			}
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.set(&t.wroteRequest) },
+		GotFirstResponseByte: func() { t.set(&t.firstByte) },
+	}
+}
+
+func (t *harTrace) timings(start, end time.Time) harTimings {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	timings := harTimings{
+		Blocked: -1,
+		DNS:     phase(t.dnsStart, t.dnsDone),
+		Connect: phase(t.connectStart, t.connectDone),
+		SSL:     phase(t.tlsStart, t.tlsDone),
+		Send:    0,
+		Wait:    0,
+		Receive: 0,
+	}
+
+	// Time spent before connecting, or waiting for a connection
+	connStart := t.gotConn
+	for _, ts := range []time.Time{t.tlsStart, t.connectStart, t.dnsStart} {
+		if !ts.IsZero() {
+			connStart = ts
+		}
+	}
+	if !connStart.IsZero() {
+		timings.Blocked = milliseconds(start, connStart)
+	}
+
+	// SSL time is part of the connect time
+	if timings.Connect >= 0 && timings.SSL >= 0 {
+		timings.Connect = milliseconds(t.connectStart, t.tlsDone)
+	}
+
+	if !t.gotConn.IsZero() && !t.wroteRequest.IsZero() {
+		timings.Send = milliseconds(t.gotConn, t.wroteRequest)
+	}
+	if !t.wroteRequest.IsZero() && !t.firstByte.IsZero() {
+		timings.Wait = milliseconds(t.wroteRequest, t.firstByte)
+	}
+	if !t.firstByte.IsZero() {
+		timings.Receive = milliseconds(t.firstByte, end)
+	}
+
+	return timings
+}
+
+func (t *harTrace) serverIP() string {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if i := strings.LastIndex(t.remoteAddr, ":"); i >= 0 {
+		return strings.Trim(t.remoteAddr[:i], "[]")
+	}
+	return t.remoteAddr
+}
+
+func phase(start, end time.Time) float64 {
+	if start.IsZero() || end.IsZero() {
+		return -1
+	}
+	return milliseconds(start, end)
+}
+
+func milliseconds(start, end time.Time) float64 {
+	return float64(end.Sub(start).Microseconds()) / 1000
+}
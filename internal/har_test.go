@@ -0,0 +1,163 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHARRecorder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/target", http.StatusFound)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("hello, world"))
+	}))
+	defer server.Close()
+
+	redactor, err := NewRedactor(nil, nil, "", []string{"s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := newHARRecorder(5, redactor)
+	pageref := recorder.page("first test")
+	wrap := func(transport http.RoundTripper) http.RoundTripper {
+		return recorder.transport(transport, pageref)
+	}
+
+	resp, body, err := SendHTTPRequest(&HTTPRequestConfig{
+		Method:        "POST",
+		URL:           server.URL + "/echo?a=1&access_token=s3cret",
+		Headers:       map[string]string{"content-type": "application/json", "authorization": "Bearer s3cret"},
+		Body:          strings.NewReader(`{"a":"bcdef"}`),
+		WrapTransport: wrap,
+	})
+	if err != nil {
+		t.Fatalf("SendHTTPRequest: unexpected error %v", err)
+	}
+	if resp.StatusCode != 200 || string(body) != "hello, world" {
+		t.Errorf("SendHTTPRequest: recording changed the response %d %q", resp.StatusCode, body)
+	}
+
+	if _, _, err := SendHTTPRequest(&HTTPRequestConfig{Method: "GET", URL: server.URL + "/redirect", WrapTransport: wrap}); err != nil {
+		t.Fatalf("SendHTTPRequest: unexpected error %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "run.har")
+	if err := recorder.write(path); err != nil {
+		t.Fatalf("write: unexpected error %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	har := struct {
+		Log harLog `json:"log"`
+	}{}
+	if err := json.Unmarshal(data, &har); err != nil {
+		t.Fatalf("write: invalid HAR file %v", err)
+	}
+
+	if len(har.Log.Pages) != 1 || har.Log.Pages[0].ID != pageref || har.Log.Pages[0].Title != "first test" {
+		t.Errorf("write: unexpected pages %+v", har.Log.Pages)
+	}
+	if len(har.Log.Entries) != 2 {
+		t.Fatalf("write: expected 2 entries, actual %d", len(har.Log.Entries))
+	}
+
+	post := har.Log.Entries[0]
+	if post.Pageref != pageref || post.Request.Method != "POST" || post.Request.BodySize != 13 {
+		t.Errorf("write: unexpected request %+v", post.Request)
+	}
+	if post.Request.PostData == nil || post.Request.PostData.Text != `{"a":` || post.Request.PostData.MimeType != "application/json" {
+		t.Errorf("write: expected truncated post data, actual %+v", post.Request.PostData)
+	}
+	if len(post.Request.QueryString) != 2 || post.Request.QueryString[0] != (harNameValue{Name: "a", Value: "1"}) ||
+		post.Request.QueryString[1] != (harNameValue{Name: "access_token", Value: redactedValue}) {
+		t.Errorf("write: unexpected query string %+v", post.Request.QueryString)
+	}
+	if strings.Contains(string(data), "s3cret") {
+		t.Errorf("write: secret value not redacted")
+	}
+	if post.Response.Status != 200 || post.Response.StatusText != "OK" || post.Response.Content.Text != "hello" ||
+		post.Response.Content.Size != 12 || post.Response.Content.Comment != "truncated to 5 of 12 bytes" {
+		t.Errorf("write: unexpected response %+v", post.Response)
+	}
+	if len(post.Response.Cookies) != 1 || post.Response.Cookies[0].Name != "session" {
+		t.Errorf("write: unexpected cookies %+v", post.Response.Cookies)
+	}
+	if post.Time < 0 || post.Timings.Wait < 0 || post.Timings.Receive < 0 || post.ServerIPAddress != "127.0.0.1" {
+		t.Errorf("write: unexpected timings %+v %s", post.Timings, post.ServerIPAddress)
+	}
+
+	redirect := har.Log.Entries[1]
+	if redirect.Response.Status != 302 || redirect.Response.RedirectURL != "/target" {
+		t.Errorf("write: unexpected redirect response %+v", redirect.Response)
+	}
+
+	// The written file can be imported again
+	tf, err := ImportHAR(data, ImportOptions{})
+	if err != nil || len(tf.Tests) != 2 {
+		t.Errorf("ImportHAR: unable to import written file %v", err)
+	}
+}
+
+func TestHARRecorderBody(t *testing.T) {
+	var tests = []struct {
+		data     []byte
+		text     string
+		encoding string
+		comment  string
+	}{
+		{[]byte("abc"), "abc", "", ""},
+		{[]byte("abcdef"), "abcd", "", "truncated to 4 of 6 bytes"},
+		{[]byte("abcé"), "abc", "", "truncated to 4 of 5 bytes"},
+		{[]byte{0xff, 0x00}, "/wA=", "base64", ""},
+	}
+
+	recorder := newHARRecorder(4, nil)
+	for _, tc := range tests {
+		text, encoding, comment := recorder.body("", tc.data)
+		if text != tc.text || encoding != tc.encoding || comment != tc.comment {
+			t.Errorf("body(%q): expected %q %q %q, actual %q %q %q", tc.data, tc.text, tc.encoding, tc.comment, text, encoding, comment)
+		}
+	}
+}
+
+func TestHARRecorderFunctionRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"token": "abc"}`))
+	}))
+	defer server.Close()
+
+	config := &Config{Host: strings.TrimPrefix(server.URL, "http://"), HARFile: filepath.Join(t.TempDir(), "run.har")}
+	if err := ApplyConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	test := &Test{Description: "with token"}
+	test.Request.Scheme = "http"
+	test.Request.Path = "/"
+	test.Request.DynamicHeaders = []DynamicHeader{{Name: "x-token", Function: "postFormURLEncoded", Args: []string{server.URL + "/token", "token"}}}
+	if result := RunTest(context.Background(), test, config); len(result.Errors) > 0 {
+		t.Fatalf("RunTest: unexpected errors %v", result.Errors)
+	}
+
+	// The token request is recorded on the page of the test
+	entries := config.har.entries
+	if len(entries) != 2 || entries[0].Request.URL != server.URL+"/token" {
+		t.Fatalf("RunTest: unexpected entries %+v", entries)
+	}
+	if entries[0].Pageref != entries[1].Pageref || entries[0].Pageref != config.har.pages[0].ID {
+		t.Errorf("RunTest: expected entries on the page of the test, actual %s and %s", entries[0].Pageref, entries[1].Pageref)
+	}
+}
@@ -28,6 +28,7 @@ import (
 
 // HTTPRequestConfig type
 type HTTPRequestConfig struct {
+	Context              context.Context `json:"-"`
 	Method               string
 	URL                  string
 	QueryParams          map[string]string
@@ -37,8 +38,13 @@ type HTTPRequestConfig struct {
 	Body                 io.Reader
 	TimeoutSeconds       time.Duration
 	SkipCertVerification bool
+	Proxy                string
+	ProxyUsername        string
+	ProxyPassword        string `json:"-"`
 	MaxRetries           int
 	RetryCallback        func(ctx context.Context, resp *http.Response, err error) (bool, error)
+	WrapTransport        func(http.RoundTripper) http.RoundTripper `json:"-"`
+	Sign                 signFunc                                  `json:"-"`
 }
 
 // SendHTTPRequest sends an HTTP request and returns response body and status
@@ -60,8 +66,14 @@ func SendHTTPRequest(config *HTTPRequestConfig) (*http.Response, []byte, error)
 		config.TimeoutSeconds = 10
 	}
 
+	ctx := config.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
 	// Create request
-	req, err := retryablehttp.NewRequest(
+	req, err := retryablehttp.NewRequestWithContext(
+		ctx,
 		config.Method,
 		config.URL,
 		config.Body,
@@ -96,15 +108,32 @@ func SendHTTPRequest(config *HTTPRequestConfig) (*http.Response, []byte, error)
 		req.Header.Add(k, v)
 	}
 
+	// Proxy
+	proxy, err := proxyFunc(config.Proxy, config.ProxyUsername, config.ProxyPassword)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid proxy: %s", err)
+	}
+
+	var transport http.RoundTripper = &http.Transport{
+		Proxy:           proxy,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: config.SkipCertVerification},
+	}
+	if config.WrapTransport != nil {
+		transport = config.WrapTransport(transport)
+	}
+
+	// Sign each attempt last, once all headers are set
+	if config.Sign != nil {
+		transport = &signingTransport{sign: config.Sign, next: transport}
+	}
+
 	client := retryablehttp.Client{
 		HTTPClient: &http.Client{
 			CheckRedirect: func(req *http.Request, via []*http.Request) error {
 				return http.ErrUseLastResponse
 			},
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: config.SkipCertVerification},
-			},
-			Timeout: time.Duration(config.TimeoutSeconds * time.Second),
+			Transport: transport,
+			Timeout:   time.Duration(config.TimeoutSeconds * time.Second),
 		},
 	}
 
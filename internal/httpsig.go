@@ -0,0 +1,283 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nytimes/httptest/functions"
+)
+
+// HTTP message signature algorithms (RFC 9421 section 3.3)
+const (
+	httpSigRSAPSSSHA512    = "rsa-pss-sha512"
+	httpSigRSAv15SHA256    = "rsa-v1_5-sha256"
+	httpSigECDSAP256SHA256 = "ecdsa-p256-sha256"
+	httpSigEd25519         = "ed25519"
+	httpSigHMACSHA256      = "hmac-sha256"
+)
+
+var httpSigAlgorithms = []string{httpSigRSAPSSSHA512, httpSigRSAv15SHA256, httpSigECDSAP256SHA256, httpSigEd25519, httpSigHMACSHA256}
+
+// Components covered when none are configured
+var defaultHTTPSigComponents = []string{"@method", "@authority", "@path"}
+
+// httpSigner signs requests with HTTP message signatures
+type httpSigner struct {
+	label      string
+	algorithm  string
+	keyID      string
+	components []string
+	expires    time.Duration
+	digest     string
+
+	// key is a crypto.Signer, or the shared secret for hmac-sha256
+	key interface{}
+}
+
+// newHTTPSigner loads the key of the signing configuration
+func newHTTPSigner(s *Signing) (*httpSigner, error) {
+	signer := &httpSigner{
+		label:      stringValue(s.Label, "sig1"),
+		algorithm:  s.Algorithm,
+		keyID:      s.KeyID,
+		components: s.Components,
+		digest:     stringValue(s.Digest, "sha-256"),
+	}
+
+	if len(signer.components) == 0 {
+		signer.components = defaultHTTPSigComponents
+	}
+
+	if len(s.Expires) > 0 {
+		expires, err := time.ParseDuration(s.Expires)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expires: %s", err)
+		}
+		signer.expires = expires
+	}
+
+	if s.Algorithm == httpSigHMACSHA256 {
+		secret, err := functions.ReadKey(s.Key)
+		if err != nil {
+			return nil, err
+		}
+		if encoded, ok := strings.CutPrefix(secret, "base64:"); ok {
+			decoded, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("invalid base64 key: %s", err)
+			}
+			secret = string(decoded)
+		}
+		signer.key = []byte(secret)
+		return signer, nil
+	}
+
+	key, err := functions.LoadPrivateKey(s.Key, s.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	var ok bool
+	switch s.Algorithm {
+	case httpSigRSAPSSSHA512, httpSigRSAv15SHA256:
+		_, ok = key.(*rsa.PrivateKey)
+	case httpSigECDSAP256SHA256:
+		var ecKey *ecdsa.PrivateKey
+		ecKey, ok = key.(*ecdsa.PrivateKey)
+		ok = ok && ecKey.Curve.Params().BitSize == 256
+	case httpSigEd25519:
+		_, ok = key.(ed25519.PrivateKey)
+	}
+	if !ok {
+		return nil, fmt.Errorf("key does not match algorithm %s", s.Algorithm)
+	}
+	signer.key = key
+
+	return signer, nil
+}
+
+// sign adds the Signature-Input and Signature headers to a request, adding a
+// Content-Digest header first if it is covered and missing
+func (s *httpSigner) sign(req *http.Request, body []byte, created time.Time) error {
+	if containsFold(s.components, "content-digest") && len(req.Header.Get("Content-Digest")) == 0 {
+		digest, err := contentDigest(s.digest, body)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Digest", digest)
+	}
+
+	params := httpSigParams(s.components, created, s.expires, s.keyID, s.algorithm)
+	base, err := httpSigBase(req, body, s.components, params)
+	if err != nil {
+		return err
+	}
+
+	signature, err := s.signBase([]byte(base))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Signature-Input", s.label+"="+params)
+	req.Header.Set("Signature", s.label+"=:"+base64.StdEncoding.EncodeToString(signature)+":")
+	return nil
+}
+
+func (s *httpSigner) signBase(base []byte) ([]byte, error) {
+	switch s.algorithm {
+	case httpSigRSAPSSSHA512:
+		digest := sha512.Sum512(base)
+		return rsa.SignPSS(rand.Reader, s.key.(*rsa.PrivateKey), crypto.SHA512, digest[:], &rsa.PSSOptions{SaltLength: 64})
+	case httpSigRSAv15SHA256:
+		digest := sha256.Sum256(base)
+		return rsa.SignPKCS1v15(nil, s.key.(*rsa.PrivateKey), crypto.SHA256, digest[:])
+	case httpSigECDSAP256SHA256:
+		digest := sha256.Sum256(base)
+		r, sig, err := ecdsa.Sign(rand.Reader, s.key.(*ecdsa.PrivateKey), digest[:])
+		if err != nil {
+			return nil, err
+		}
+
+		// Signatures are the fixed size concatenation of r and s rather than ASN.1
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		sig.FillBytes(signature[32:])
+		return signature, nil
+	case httpSigEd25519:
+		return ed25519.Sign(s.key.(ed25519.PrivateKey), base), nil
+	case httpSigHMACSHA256:
+		mac := hmac.New(sha256.New, s.key.([]byte))
+		mac.Write(base)
+		return mac.Sum(nil), nil
+	}
+
+	return nil, fmt.Errorf("unsupported algorithm %q", s.algorithm)
+}
+
+// httpSigParams returns the inner list of covered components with signature
+// parameters, as used in Signature-Input and the signature base
+func httpSigParams(components []string, created time.Time, expires time.Duration, keyID, algorithm string) string {
+	quoted := make([]string, len(components))
+	for i, component := range components {
+		quoted[i] = strconv.Quote(strings.ToLower(component))
+	}
+
+	params := "(" + strings.Join(quoted, " ") + ");created=" + strconv.FormatInt(created.Unix(), 10)
+	if expires > 0 {
+		params += ";expires=" + strconv.FormatInt(created.Add(expires).Unix(), 10)
+	}
+	if len(keyID) > 0 {
+		params += ";keyid=" + strconv.Quote(keyID)
+	}
+	if len(algorithm) > 0 {
+		params += ";alg=" + strconv.Quote(algorithm)
+	}
+	return params
+}
+
+// httpSigBase returns the signature base of a request (RFC 9421 section 2.5)
+func httpSigBase(req *http.Request, body []byte, components []string, params string) (string, error) {
+	var buf strings.Builder
+	for _, component := range components {
+		name := strings.ToLower(component)
+		value, err := httpSigComponent(req, body, name)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&buf, "%q: %s\n", name, value)
+	}
+	fmt.Fprintf(&buf, "%q: %s", "@signature-params", params)
+	return buf.String(), nil
+}
+
+// httpSigComponent returns the value of a derived component or header field
+func httpSigComponent(req *http.Request, body []byte, name string) (string, error) {
+	switch name {
+	case "@method":
+		return req.Method, nil
+	case "@target-uri":
+		return req.URL.String(), nil
+	case "@authority":
+		return httpSigAuthority(req), nil
+	case "@scheme":
+		return strings.ToLower(req.URL.Scheme), nil
+	case "@request-target":
+		return req.URL.RequestURI(), nil
+	case "@path":
+		return stringValue(req.URL.EscapedPath(), "/"), nil
+	case "@query":
+		return "?" + req.URL.RawQuery, nil
+	}
+
+	if strings.HasPrefix(name, "@") {
+		return "", fmt.Errorf("unsupported derived component %q", name)
+	}
+
+	// Go keeps the host and content length out of the header map
+	switch name {
+	case "host":
+		return httpSigAuthority(req), nil
+	case "content-length":
+		if len(req.Header.Values(name)) == 0 {
+			return strconv.Itoa(len(body)), nil
+		}
+	}
+
+	// Copied, as Values returns the slice of the request headers
+	values := append([]string(nil), req.Header.Values(name)...)
+	if len(values) == 0 {
+		return "", fmt.Errorf("covered header %q is not set", name)
+	}
+	for i, value := range values {
+		values[i] = strings.TrimSpace(value)
+	}
+	return strings.Join(values, ", "), nil
+}
+
+// httpSigAuthority returns the lowercase host of a request, without the default port
+func httpSigAuthority(req *http.Request) string {
+	host := strings.ToLower(stringValue(req.Host, req.URL.Host))
+	if (req.URL.Scheme == "https" && strings.HasSuffix(host, ":443")) || (req.URL.Scheme == "http" && strings.HasSuffix(host, ":80")) {
+		host = host[:strings.LastIndex(host, ":")]
+	}
+	return host
+}
+
+// contentDigest returns a Content-Digest header value for a body (RFC 9530)
+func contentDigest(algorithm string, body []byte) (string, error) {
+	switch algorithm {
+	case "sha-256":
+		digest := sha256.Sum256(body)
+		return "sha-256=:" + base64.StdEncoding.EncodeToString(digest[:]) + ":", nil
+	case "sha-512":
+		digest := sha512.Sum512(body)
+		return "sha-512=:" + base64.StdEncoding.EncodeToString(digest[:]) + ":", nil
+	}
+	return "", fmt.Errorf("unsupported digest algorithm %q. supported: sha-256, sha-512", algorithm)
+}
@@ -0,0 +1,542 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultStripHeaders are volatile headers left out of imported tests by default
+var DefaultStripHeaders = []string{"cookie", "set-cookie", "date", "if-none-match", "if-modified-since", "age", "expires", "last-modified", "etag"}
+
+// DefaultAssertHeaders are the response headers imported tests assert on by default
+var DefaultAssertHeaders = []string{"content-type"}
+
+// Request headers never copied into imported tests; they are set by the HTTP client
+var skippedRequestHeaders = map[string]bool{
+	"host":              true,
+	"content-length":    true,
+	"connection":        true,
+	"accept-encoding":   true,
+	"transfer-encoding": true,
+}
+
+// ImportOptions controls how requests are converted into tests
+type ImportOptions struct {
+	StripHeaders  []string // Request and response headers to leave out
+	AssertHeaders []string // Response headers to assert on, when the response is known
+}
+
+func (o ImportOptions) stripped(header string) bool {
+	header = strings.ToLower(header)
+	if strings.HasPrefix(header, ":") || skippedRequestHeaders[header] {
+		return true
+	}
+	for _, h := range o.StripHeaders {
+		if strings.ToLower(h) == header {
+			return true
+		}
+	}
+	return false
+}
+
+// harFile is the subset of the HAR 1.2 format needed to import requests
+type harFile struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Method   string         `json:"method"`
+				URL      string         `json:"url"`
+				Headers  []harNameValue `json:"headers"`
+				PostData *struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+				} `json:"postData"`
+			} `json:"request"`
+			Response struct {
+				Status  int            `json:"status"`
+				Headers []harNameValue `json:"headers"`
+			} `json:"response"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ImportHAR converts the entries of a HAR file into tests
+func ImportHAR(data []byte, opts ImportOptions) (*TestFile, error) {
+	har := harFile{}
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("invalid HAR file: %s", err)
+	}
+
+	tf := &TestFile{}
+	for i, entry := range har.Log.Entries {
+		test, err := newImportedTest(entry.Request.Method, entry.Request.URL)
+		if err != nil {
+			return nil, fmt.Errorf("HAR entry %d: %s", i, err)
+		}
+
+		for _, header := range entry.Request.Headers {
+			if !opts.stripped(header.Name) {
+				test.Request.Headers[strings.ToLower(header.Name)] = header.Value
+			}
+		}
+
+		if entry.Request.PostData != nil {
+			test.Request.Body = entry.Request.PostData.Text
+			if _, ok := test.Request.Headers["content-type"]; !ok && len(entry.Request.PostData.MimeType) > 0 {
+				test.Request.Headers["content-type"] = entry.Request.PostData.MimeType
+			}
+		}
+
+		// Observed response as assertions
+		if entry.Response.Status > 0 {
+			test.Response.StatusCodes = []int{entry.Response.Status}
+		}
+		test.Response.Headers.Patterns = map[string]string{}
+		for _, header := range entry.Response.Headers {
+			name := strings.ToLower(header.Name)
+			if opts.stripped(name) || !containsFold(opts.AssertHeaders, name) {
+				continue
+			}
+			test.Response.Headers.Patterns[name] = exactPattern(header.Value)
+		}
+
+		tf.Tests = append(tf.Tests, test)
+	}
+
+	return tf, nil
+}
+
+// ImportCurl converts a curl command line into a test
+func ImportCurl(command string, opts ImportOptions) (*Test, error) {
+	args, err := splitShellWords(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) > 0 && args[0] == "curl" {
+		args = args[1:]
+	}
+
+	var (
+		method, rawURL, proxy string
+		headers               [][2]string
+		data                  []string
+		get, insecure, isJSON bool
+	)
+
+	args = splitCurlFlags(args)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		// Value of a flag, either attached (--request=POST) or the next argument
+		value := func() (string, error) {
+			if strings.HasPrefix(arg, "--") {
+				if idx := strings.Index(arg, "="); idx > 0 {
+					return arg[idx+1:], nil
+				}
+			}
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("curl option %s requires a value", arg)
+			}
+			i++
+			return args[i], nil
+		}
+
+		name := arg
+		if strings.HasPrefix(arg, "--") {
+			name = strings.SplitN(arg, "=", 2)[0]
+		}
+
+		var v string
+		switch name {
+		case "-X", "--request":
+			if v, err = value(); err == nil {
+				method = strings.ToUpper(v)
+			}
+		case "-H", "--header":
+			if v, err = value(); err == nil {
+				parts := strings.SplitN(v, ":", 2)
+				if len(parts) == 2 {
+					headers = append(headers, [2]string{parts[0], strings.TrimSpace(parts[1])})
+				}
+			}
+		case "--data-raw":
+			if v, err = value(); err == nil {
+				data = append(data, v)
+			}
+		case "-d", "--data", "--data-binary", "--data-ascii", "--json":
+			if v, err = value(); err == nil {
+				if v, err = curlData(name, v); err == nil {
+					data = append(data, v)
+					isJSON = isJSON || name == "--json"
+				}
+			}
+		case "--data-urlencode":
+			if v, err = value(); err == nil {
+				if v, err = curlURLEncodedData(v); err == nil {
+					data = append(data, v)
+				}
+			}
+		case "-u", "--user":
+			if v, err = value(); err == nil {
+				headers = append(headers, [2]string{"authorization", "Basic " + base64.StdEncoding.EncodeToString([]byte(v))})
+			}
+		case "-b", "--cookie":
+			if v, err = value(); err == nil {
+				headers = append(headers, [2]string{"cookie", v})
+			}
+		case "-A", "--user-agent":
+			if v, err = value(); err == nil {
+				headers = append(headers, [2]string{"user-agent", v})
+			}
+		case "-e", "--referer":
+			if v, err = value(); err == nil {
+				headers = append(headers, [2]string{"referer", v})
+			}
+		case "-x", "--proxy":
+			proxy, err = value()
+		case "--url":
+			rawURL, err = value()
+		case "-I", "--head":
+			method = "HEAD"
+		case "-G", "--get":
+			get = true
+		case "-k", "--insecure":
+			insecure = true
+		default:
+			if unsupportedCurlFlags[name] {
+				err = fmt.Errorf("unsupported curl option %s", name)
+			} else if curlValueFlags[name] {
+				_, err = value()
+			} else if !strings.HasPrefix(arg, "-") && len(rawURL) == 0 {
+				rawURL = arg
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(rawURL) == 0 {
+		return nil, fmt.Errorf("no URL found in curl command")
+	}
+
+	body := strings.Join(data, "&")
+
+	// Methods implied by curl options
+	if len(method) == 0 {
+		switch {
+		case get:
+			method = "GET"
+		case len(data) > 0:
+			method = "POST"
+		default:
+			method = "GET"
+		}
+	}
+
+	// -G sends data in the query string
+	if get && len(body) > 0 {
+		separator := "?"
+		if strings.Contains(rawURL, "?") {
+			separator = "&"
+		}
+		rawURL += separator + body
+		body = ""
+	}
+
+	test, err := newImportedTest(method, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, header := range headers {
+		if !opts.stripped(header[0]) {
+			test.Request.Headers[strings.ToLower(header[0])] = header[1]
+		}
+	}
+
+	if len(body) > 0 {
+		test.Request.Body = body
+		if _, ok := test.Request.Headers["content-type"]; !ok {
+			if isJSON {
+				test.Request.Headers["content-type"] = "application/json"
+			} else {
+				test.Request.Headers["content-type"] = "application/x-www-form-urlencoded"
+			}
+		}
+	}
+
+	test.SkipCertVerification = insecure
+	test.Request.Proxy = proxy
+
+	return test, nil
+}
+
+// Curl options that take a value, including those that don't affect the test
+var curlValueFlags = map[string]bool{
+	"-X": true, "--request": true, "-H": true, "--header": true,
+	"-d": true, "--data": true, "--data-raw": true, "--data-binary": true, "--data-ascii": true,
+	"--data-urlencode": true, "--json": true, "-u": true, "--user": true, "-b": true, "--cookie": true,
+	"-A": true, "--user-agent": true, "-e": true, "--referer": true, "-x": true, "--proxy": true, "--url": true,
+	"-o": true, "--output": true, "-m": true, "--max-time": true, "--connect-timeout": true,
+	"-w": true, "--write-out": true, "--retry": true, "-c": true, "--cookie-jar": true,
+	"--resolve": true, "--cacert": true, "--cert": true, "--key": true,
+}
+
+// Curl options whose requests can't be imported
+var unsupportedCurlFlags = map[string]bool{
+	"-F": true, "--form": true, "--form-string": true, "-T": true, "--upload-file": true, "-K": true, "--config": true,
+}
+
+// splitCurlFlags splits grouped short options (-sk, -skXPOST) into separate
+// arguments, with the attached value of the last option as the next argument.
+// Option values are left unchanged.
+func splitCurlFlags(args []string) []string {
+	var split []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "--") || len(arg) < 2 {
+			split = append(split, arg)
+			if curlValueFlags[strings.SplitN(arg, "=", 2)[0]] && !strings.Contains(arg, "=") && i+1 < len(args) {
+				i++
+				split = append(split, args[i])
+			}
+			continue
+		}
+
+		for j := 1; j < len(arg); j++ {
+			flag := "-" + arg[j:j+1]
+			split = append(split, flag)
+			if !curlValueFlags[flag] && !unsupportedCurlFlags[flag] {
+				continue
+			}
+			if j+1 < len(arg) {
+				split = append(split, arg[j+1:])
+			} else if i+1 < len(args) {
+				i++
+				split = append(split, args[i])
+			}
+			break
+		}
+	}
+	return split
+}
+
+// curlData returns the request body of a curl data option. Like curl,
+// @file reads the body from a file, with newlines removed except for
+// --data-binary and --json.
+func curlData(flag, value string) (string, error) {
+	if !strings.HasPrefix(value, "@") {
+		return value, nil
+	}
+	data, err := readCurlFile(flag, value[1:])
+	if err != nil {
+		return "", err
+	}
+	if flag != "--data-binary" && flag != "--json" {
+		data = strings.NewReplacer("\r", "", "\n", "").Replace(data)
+	}
+	return data, nil
+}
+
+// curlURLEncodedData returns the request body of a --data-urlencode option:
+// content, =content, name=content, @file or name@file
+func curlURLEncodedData(value string) (string, error) {
+	name, content := "", value
+	if idx := strings.IndexAny(value, "=@"); idx >= 0 {
+		name, content = value[:idx], value[idx+1:]
+		if value[idx] == '@' {
+			data, err := readCurlFile("--data-urlencode", content)
+			if err != nil {
+				return "", err
+			}
+			content = data
+		}
+	}
+	if len(name) > 0 {
+		return name + "=" + url.QueryEscape(content), nil
+	}
+	return url.QueryEscape(content), nil
+}
+
+// readCurlFile reads the file of a curl @file option value
+func readCurlFile(flag, path string) (string, error) {
+	if path == "-" {
+		return "", fmt.Errorf("unsupported curl option %s @-: reading from stdin", flag)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read data of curl option %s: %s", flag, err)
+	}
+	return string(data), nil
+}
+
+// newImportedTest creates a test for a request method and URL
+func newImportedTest(method, rawURL string) (*Test, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported URL %q", rawURL)
+	}
+
+	test := &Test{}
+	test.Request.Method = strings.ToUpper(stringValue(method, "GET"))
+	test.Request.Host = u.Host
+	test.Request.Path = stringValue(u.EscapedPath(), "/")
+	if len(u.RawQuery) > 0 {
+		test.Request.Path += "?" + u.RawQuery
+	}
+	test.Request.Headers = map[string]string{}
+	test.Description = fmt.Sprintf("%s %s", test.Request.Method, test.Request.Path)
+
+	// https is the default
+	if u.Scheme == "http" {
+		test.Request.Scheme = "http"
+	}
+
+	return test, nil
+}
+
+// exactPattern returns a pattern matching exactly the given value
+func exactPattern(value string) string {
+	return "^" + regexp.QuoteMeta(value) + "$"
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitShellWords splits a command line into words like a POSIX shell, including $'...' quoting
+func splitShellWords(command string) ([]string, error) {
+	words := []string{}
+	var word strings.Builder
+	inWord := false
+
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == '\\' && i+1 < len(command):
+			i++
+			if command[i] != '\n' && command[i] != '\r' {
+				word.WriteByte(command[i])
+				inWord = true
+			}
+		case c == '\'':
+			end := strings.IndexByte(command[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote in command")
+			}
+			word.WriteString(command[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '$' && i+1 < len(command) && command[i+1] == '\'':
+			s, n, err := readANSICQuoted(command[i+2:])
+			if err != nil {
+				return nil, err
+			}
+			word.WriteString(s)
+			i += n + 1
+			inWord = true
+		case c == '"':
+			i++
+			for ; i < len(command) && command[i] != '"'; i++ {
+				if command[i] == '\\' && i+1 < len(command) && strings.IndexByte("\"\\$`\n", command[i+1]) >= 0 {
+					i++
+					if command[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(command[i])
+			}
+			if i >= len(command) {
+				return nil, fmt.Errorf("unterminated double quote in command")
+			}
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// readANSICQuoted reads the body of a $'...' string, returning it unescaped and the number of bytes consumed
+func readANSICQuoted(s string) (string, int, error) {
+	var buffer strings.Builder
+	escapes := map[byte]byte{'n': '\n', 't': '\t', 'r': '\r', '\\': '\\', '\'': '\'', '"': '"', 'a': '\a', 'b': '\b', 'e': 0x1b, 'f': '\f', 'v': '\v'}
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\'':
+			return buffer.String(), i + 1, nil
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			if e, ok := escapes[s[i]]; ok {
+				buffer.WriteByte(e)
+			} else if s[i] == 'x' && i+2 < len(s) {
+				b, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+				if err != nil {
+					return "", 0, fmt.Errorf("invalid escape in command: %s", err)
+				}
+				buffer.WriteByte(byte(b))
+				i += 2
+			} else if s[i] == 'u' && i+4 < len(s) {
+				r, err := strconv.ParseUint(s[i+1:i+5], 16, 32)
+				if err != nil {
+					return "", 0, fmt.Errorf("invalid escape in command: %s", err)
+				}
+				buffer.WriteRune(rune(r))
+				i += 4
+			} else {
+				buffer.WriteByte('\\')
+				buffer.WriteByte(s[i])
+			}
+		default:
+			buffer.WriteByte(s[i])
+		}
+	}
+
+	return "", 0, fmt.Errorf("unterminated $' quote in command")
+}
@@ -0,0 +1,202 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testHAR = `{
+  "log": {
+    "version": "1.2",
+    "entries": [
+      {
+        "request": {
+          "method": "POST",
+          "url": "http://api.example.com/v1/items?debug=1",
+          "headers": [
+            {"name": ":authority", "value": "api.example.com"},
+            {"name": "Content-Type", "value": "application/json"},
+            {"name": "Cookie", "value": "session=abc"},
+            {"name": "X-Client", "value": "web"}
+          ],
+          "postData": {"mimeType": "application/json", "text": "{\"name\":\"a\"}"}
+        },
+        "response": {
+          "status": 201,
+          "headers": [
+            {"name": "Content-Type", "value": "application/json; charset=utf-8"},
+            {"name": "Date", "value": "Mon, 01 Jan 2024 00:00:00 GMT"},
+            {"name": "Cache-Control", "value": "no-store"}
+          ]
+        }
+      }
+    ]
+  }
+}`
+
+func TestImportHAR(t *testing.T) {
+	tf, err := ImportHAR([]byte(testHAR), ImportOptions{StripHeaders: DefaultStripHeaders, AssertHeaders: []string{"content-type", "date", "cache-control"}})
+	if err != nil {
+		t.Fatalf("ImportHAR: unexpected error %v", err)
+	}
+	if len(tf.Tests) != 1 {
+		t.Fatalf("ImportHAR: expected 1 test, actual %d", len(tf.Tests))
+	}
+
+	test := tf.Tests[0]
+	if test.Description != "POST /v1/items?debug=1" || test.Request.Scheme != "http" || test.Request.Host != "api.example.com" ||
+		test.Request.Method != "POST" || test.Request.Path != "/v1/items?debug=1" || test.Request.Body != `{"name":"a"}` {
+		t.Errorf("ImportHAR: unexpected request %+v", test.Request)
+	}
+
+	expectedHeaders := map[string]string{"content-type": "application/json", "x-client": "web"}
+	if !reflect.DeepEqual(test.Request.Headers, expectedHeaders) {
+		t.Errorf("ImportHAR: expected headers %v, actual %v", expectedHeaders, test.Request.Headers)
+	}
+
+	if !reflect.DeepEqual(test.Response.StatusCodes, []int{201}) {
+		t.Errorf("ImportHAR: expected status codes [201], actual %v", test.Response.StatusCodes)
+	}
+
+	expectedPatterns := map[string]string{"content-type": `^application/json; charset=utf-8$`, "cache-control": `^no-store$`}
+	if !reflect.DeepEqual(test.Response.Headers.Patterns, expectedPatterns) {
+		t.Errorf("ImportHAR: expected patterns %v, actual %v", expectedPatterns, test.Response.Headers.Patterns)
+	}
+}
+
+func TestImportCurl(t *testing.T) {
+	var tests = []struct {
+		command  string
+		method   string
+		path     string
+		headers  map[string]string
+		body     string
+		insecure bool
+	}{
+		{
+			`curl https://example.com`,
+			"GET", "/", map[string]string{}, "", false,
+		},
+		{
+			"curl 'https://example.com/a?b=c' \\\n  -H 'Accept: application/json' \\\n  -H 'cookie: a=b' \\\n  --compressed",
+			"GET", "/a?b=c", map[string]string{"accept": "application/json"}, "", false,
+		},
+		{
+			`curl -XPUT -k --data-raw $'{"a":"it\'s"}' -H "Content-Type: application/json" https://example.com/put`,
+			"PUT", "/put", map[string]string{"content-type": "application/json"}, `{"a":"it's"}`, true,
+		},
+		{
+			`curl -sS -d a=1 -d b=2 https://example.com/form -o /dev/null`,
+			"POST", "/form", map[string]string{"content-type": "application/x-www-form-urlencoded"}, "a=1&b=2", false,
+		},
+		{
+			`curl -G --data-urlencode "q=a b" https://example.com/search`,
+			"GET", "/search?q=a+b", map[string]string{}, "", false,
+		},
+		{
+			`curl -I -u user:pass https://example.com/`,
+			"HEAD", "/", map[string]string{"authorization": "Basic dXNlcjpwYXNz"}, "", false,
+		},
+		{
+			`curl -sk https://example.com/`,
+			"GET", "/", map[string]string{}, "", true,
+		},
+		{
+			`curl -skX POST -d -sk https://example.com/post`,
+			"POST", "/post", map[string]string{"content-type": "application/x-www-form-urlencoded"}, "-sk", true,
+		},
+		{
+			`curl -skXDELETE https://example.com/item`,
+			"DELETE", "/item", map[string]string{}, "", true,
+		},
+		{
+			`curl -d @form.txt https://example.com/form`,
+			"POST", "/form", map[string]string{"content-type": "application/x-www-form-urlencoded"}, "a=1&b=2", false,
+		},
+		{
+			`curl --data-binary @form.txt https://example.com/form`,
+			"POST", "/form", map[string]string{"content-type": "application/x-www-form-urlencoded"}, "a=1&b=2\n", false,
+		},
+		{
+			`curl --json @body.json https://example.com/json`,
+			"POST", "/json", map[string]string{"content-type": "application/json"}, "{\"a\": 1}\n", false,
+		},
+		{
+			`curl -G --data-urlencode q@query.txt https://example.com/search`,
+			"GET", "/search?q=a+b", map[string]string{}, "", false,
+		},
+		{
+			`curl --data-raw @form.txt https://example.com/form`,
+			"POST", "/form", map[string]string{"content-type": "application/x-www-form-urlencoded"}, "@form.txt", false,
+		},
+	}
+
+	// Files of @file data options, relative to the working directory
+	dir := t.TempDir()
+	files := map[string]string{"form.txt": "a=1&b=2\n", "body.json": "{\"a\": 1}\n", "query.txt": "a b"}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	for _, tc := range tests {
+		test, err := ImportCurl(tc.command, ImportOptions{StripHeaders: DefaultStripHeaders})
+		if err != nil {
+			t.Errorf("ImportCurl(%s): unexpected error %v", tc.command, err)
+			continue
+		}
+		if test.Request.Method != tc.method || test.Request.Path != tc.path || test.Request.Body != tc.body ||
+			test.SkipCertVerification != tc.insecure || !reflect.DeepEqual(test.Request.Headers, tc.headers) {
+			t.Errorf("ImportCurl(%s): expected %s %s %v %q %v, actual %+v %v", tc.command, tc.method, tc.path, tc.headers, tc.body, tc.insecure, test.Request, test.SkipCertVerification)
+		}
+	}
+
+	var errorTests = []struct {
+		command string
+		err     string
+	}{
+		{`curl -H 'a: b'`, "no URL found"},
+		{`curl -sF a=b https://example.com/upload`, "unsupported curl option -F"},
+		{`curl --upload-file a.txt https://example.com/upload`, "unsupported curl option --upload-file"},
+		{`curl -d @- https://example.com/form`, "unsupported curl option -d @-"},
+		{`curl -d @missing.txt https://example.com/form`, "unable to read data of curl option -d"},
+	}
+
+	for _, tc := range errorTests {
+		if _, err := ImportCurl(tc.command, ImportOptions{}); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("ImportCurl(%s): expected error %q, actual %v", tc.command, tc.err, err)
+		}
+	}
+}
+
+func TestSplitShellWords(t *testing.T) {
+	var tests = []struct {
+		command  string
+		expected []string
+	}{
+		{`a b  c`, []string{"a", "b", "c"}},
+		{`a 'b c' "d \"e\""`, []string{"a", "b c", `d "e"`}},
+		{"a \\\n b", []string{"a", "b"}},
+		{`a $'b\nc\x41'`, []string{"a", "b\ncA"}},
+		{`a''b ""`, []string{"ab", ""}},
+	}
+
+	for _, tc := range tests {
+		actual, err := splitShellWords(tc.command)
+		if err != nil || !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("splitShellWords(%s): expected %q, actual %q (%v)", tc.command, tc.expected, actual, err)
+		}
+	}
+}
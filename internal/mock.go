@@ -0,0 +1,227 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// MockHitsPath is the path of the mock server that lists how often each mock was served
+const MockHitsPath = "/__httptest/hits"
+
+// Mock is the response served for the request of a test in serve mode
+type Mock struct {
+	Status   int               `yaml:"status,omitempty"`
+	Headers  map[string]string `yaml:"headers,omitempty"`
+	Body     string            `yaml:"body,omitempty"`
+	BodyFile string            `yaml:"bodyFile,omitempty"`
+}
+
+// MockHit is the number of times the mock of a test was served
+type MockHit struct {
+	Filename    string `json:"filename"`
+	Description string `json:"description"`
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Hits        int    `json:"hits"`
+}
+
+// mockStub matches requests to the mock of a test
+type mockStub struct {
+	test    *Test
+	method  string
+	path    *regexp.Regexp
+	query   map[string]string
+	headers map[string]string
+	status  int
+	body    []byte
+}
+
+// templatePattern matches template expressions, which match any value
+var templatePattern = regexp.MustCompile(`{{.*?}}`)
+
+// newMockStub prepares the mock of a test
+func newMockStub(test *Test) (*mockStub, error) {
+	mock := test.Mock
+	stub := &mockStub{
+		test:    test,
+		method:  strings.ToUpper(stringValue(test.Request.Method, "GET")),
+		query:   map[string]string{},
+		headers: map[string]string{},
+		status:  mock.Status,
+		body:    []byte(mock.Body),
+	}
+
+	// Path segments with template expressions match any value
+	path, rawQuery, _ := strings.Cut(test.Request.Path, "?")
+	pattern := "^"
+	for _, part := range templatePattern.Split(stringValue(path, "/"), -1) {
+		pattern += regexp.QuoteMeta(part) + "[^/]*"
+	}
+	stub.path = regexp.MustCompile(strings.TrimSuffix(pattern, "[^/]*") + "$")
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query in path %s: %s", test.Request.Path, err)
+	}
+	for name := range query {
+		stub.query[name] = query.Get(name)
+	}
+	for name, value := range test.Request.Query {
+		stub.query[name] = value
+	}
+	for name, value := range test.Request.Headers {
+		stub.headers[name] = value
+	}
+
+	if stub.status == 0 {
+		stub.status = http.StatusOK
+		if len(test.Response.StatusCodes) > 0 {
+			stub.status = test.Response.StatusCodes[0]
+		}
+	}
+
+	if len(mock.BodyFile) > 0 {
+		if len(mock.Body) > 0 {
+			return nil, fmt.Errorf("mock body and bodyFile are mutually exclusive")
+		}
+		bodyFile := mock.BodyFile
+		if !filepath.IsAbs(bodyFile) {
+			bodyFile = filepath.Join(test.Directory, bodyFile)
+		}
+		if stub.body, err = os.ReadFile(bodyFile); err != nil {
+			return nil, fmt.Errorf("unable to read mock body file: %s", err)
+		}
+	}
+
+	return stub, nil
+}
+
+// matches reports whether a request has the method, path, query params and
+// headers of the test. Values with template expressions only have to be present.
+func (s *mockStub) matches(r *http.Request) bool {
+	if r.Method != s.method || !s.path.MatchString(r.URL.Path) {
+		return false
+	}
+
+	query := r.URL.Query()
+	for name, value := range s.query {
+		if !query.Has(name) || !matchesMockValue(value, query.Get(name)) {
+			return false
+		}
+	}
+	for name, value := range s.headers {
+		if len(r.Header.Values(name)) == 0 || !matchesMockValue(value, r.Header.Get(name)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchesMockValue(expected, actual string) bool {
+	return templatePattern.MatchString(expected) || expected == actual
+}
+
+// MockServer serves the mock responses of tests. Requests are matched to the
+// tests in order, and the first match is served.
+type MockServer struct {
+	stubs []*mockStub
+	log   io.Writer
+
+	mux  sync.Mutex
+	hits []int
+}
+
+// NewMockServer returns a server for the tests with a mock response. Requests
+// are logged to log.
+func NewMockServer(tests []*Test, log io.Writer) (*MockServer, error) {
+	s := &MockServer{log: log}
+	for _, test := range tests {
+		if test.Mock == nil {
+			continue
+		}
+		stub, err := newMockStub(test)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mock of %s | %s: %s", test.Filename, test.Description, err)
+		}
+		s.stubs = append(s.stubs, stub)
+	}
+	if len(s.stubs) == 0 {
+		return nil, fmt.Errorf("no tests with a mock response")
+	}
+	s.hits = make([]int, len(s.stubs))
+
+	return s, nil
+}
+
+func (s *MockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && r.URL.Path == MockHitsPath {
+		w.Header().Set("content-type", "application/json")
+		json.NewEncoder(w).Encode(s.Hits())
+		return
+	}
+
+	for i, stub := range s.stubs {
+		if !stub.matches(r) {
+			continue
+		}
+
+		s.mux.Lock()
+		s.hits[i]++
+		fmt.Fprintf(s.log, "%s %s -> %d | %s | %s\n", r.Method, r.URL.RequestURI(), stub.status, stub.test.Filename, stub.test.Description)
+		s.mux.Unlock()
+
+		for name, value := range stub.test.Mock.Headers {
+			w.Header().Set(name, value)
+		}
+		w.WriteHeader(stub.status)
+		w.Write(stub.body)
+		return
+	}
+
+	s.mux.Lock()
+	fmt.Fprintf(s.log, "%s %s -> %d | no matching mock\n", r.Method, r.URL.RequestURI(), http.StatusNotFound)
+	s.mux.Unlock()
+
+	http.Error(w, fmt.Sprintf("no mock matches %s %s", r.Method, r.URL.RequestURI()), http.StatusNotFound)
+}
+
+// Hits returns how often the mock of each test was served, in the order of the tests
+func (s *MockServer) Hits() []MockHit {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	hits := make([]MockHit, len(s.stubs))
+	for i, stub := range s.stubs {
+		hits[i] = MockHit{
+			Filename:    stub.test.Filename,
+			Description: stub.test.Description,
+			Method:      stub.method,
+			Path:        stub.test.Request.Path,
+			Hits:        s.hits[i],
+		}
+	}
+	return hits
+}
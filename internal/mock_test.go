@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMockServer(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "order.json"), []byte(`{"id": 1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "tests.yml")
+	data := `
+tests:
+  - description: 'get order'
+    request:
+      path: '/orders/{{id}}?expand=items'
+      headers:
+        authorization: 'Bearer {{token}}'
+    response:
+      statusCodes: [200]
+    mock:
+      headers:
+        content-type: application/json
+      bodyFile: order.json
+  - description: 'create order'
+    request:
+      method: POST
+      path: '/orders'
+      headers:
+        content-type: application/json
+    mock:
+      status: 201
+      body: '{"id": 2}'
+  - description: 'no mock'
+    request:
+      path: '/health'
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests, err := parseTestFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log bytes.Buffer
+	server, err := NewMockServer(tests, &log)
+	if err != nil {
+		t.Fatalf("NewMockServer: unexpected error %v", err)
+	}
+
+	var requests = []struct {
+		method  string
+		target  string
+		headers map[string]string
+		status  int
+		body    string
+	}{
+		{"GET", "/orders/1?expand=items", map[string]string{"authorization": "Bearer abc"}, 200, `{"id": 1}`},
+		{"GET", "/orders/1?expand=items&page=2", map[string]string{"authorization": "Bearer abc"}, 200, `{"id": 1}`},
+		{"GET", "/orders/1", map[string]string{"authorization": "Bearer abc"}, 404, ""},
+		{"GET", "/orders/1?expand=items", nil, 404, ""},
+		{"GET", "/orders/1/items?expand=items", map[string]string{"authorization": "Bearer abc"}, 404, ""},
+		{"POST", "/orders", map[string]string{"content-type": "application/json"}, 201, `{"id": 2}`},
+		{"POST", "/orders", map[string]string{"content-type": "text/plain"}, 404, ""},
+		{"GET", "/health", nil, 404, ""},
+	}
+
+	for _, tc := range requests {
+		req := httptest.NewRequest(tc.method, tc.target, nil)
+		for name, value := range tc.headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		if rec.Code != tc.status {
+			t.Errorf("%s %s: expected status %d, actual %d", tc.method, tc.target, tc.status, rec.Code)
+		}
+		if tc.status != 404 && rec.Body.String() != tc.body {
+			t.Errorf("%s %s: expected body %s, actual %s", tc.method, tc.target, tc.body, rec.Body.String())
+		}
+	}
+
+	if !strings.Contains(log.String(), "GET /orders/1?expand=items -> 200 | tests.yml | get order") {
+		t.Errorf("ServeHTTP: unexpected log %s", log.String())
+	}
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("GET", MockHitsPath, nil))
+	hits := []MockHit{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &hits); err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 2 || hits[0].Hits != 2 || hits[1].Hits != 1 || hits[1].Method != http.MethodPost {
+		t.Errorf("Hits: unexpected hits %+v", hits)
+	}
+}
+
+func TestNewMockServerErrors(t *testing.T) {
+	var tests = []struct {
+		test *Test
+		err  string
+	}{
+		{&Test{Description: "plain"}, "no tests with a mock response"},
+		{&Test{Description: "both", Mock: &Mock{Body: "a", BodyFile: "a.json"}}, "mutually exclusive"},
+		{&Test{Description: "missing", Mock: &Mock{BodyFile: "missing.json"}, Directory: t.TempDir()}, "unable to read mock body file"},
+	}
+
+	for _, tc := range tests {
+		_, err := NewMockServer([]*Test{tc.test}, &bytes.Buffer{})
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("NewMockServer(%s): expected error %q, actual %v", tc.test.Description, tc.err, err)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nytimes/httptest/functions"
+	"go.uber.org/zap"
+)
+
+// AuthOAuth2 authorizes requests with OAuth 2.0 access tokens
+const AuthOAuth2 = "oauth2"
+
+// OAuth 2.0 grant types
+const (
+	GrantClientCredentials = "clientCredentials"
+	GrantPassword          = "password"
+	GrantRefreshToken      = "refreshToken"
+	GrantJWTBearer         = "jwtBearer"
+)
+
+// Tokens are refreshed this long before they expire
+const tokenRefreshMargin = 30 * time.Second
+
+// Auth configures how a test request is authorized
+type Auth struct {
+	Type         string   `yaml:"type"`
+	TokenURL     string   `yaml:"tokenUrl"`
+	GrantType    string   `yaml:"grantType,omitempty"`
+	ClientID     string   `yaml:"clientId,omitempty"`
+	ClientSecret string   `yaml:"clientSecret,omitempty"`
+	ClientAuth   string   `yaml:"clientAuth,omitempty"`
+	Username     string   `yaml:"username,omitempty"`
+	Password     string   `yaml:"password,omitempty"`
+	RefreshToken string   `yaml:"refreshToken,omitempty"`
+	Assertion    string   `yaml:"assertion,omitempty"`
+	JWT          *AuthJWT `yaml:"jwt,omitempty"`
+	Scopes       []string `yaml:"scopes,omitempty"`
+	Audience     string   `yaml:"audience,omitempty"`
+}
+
+// AuthJWT is a JWT assertion signed for each token request, as with the signJWT function
+type AuthJWT struct {
+	Algorithm  string            `yaml:"algorithm"`
+	Key        string            `yaml:"key"`
+	Passphrase string            `yaml:"passphrase,omitempty"`
+	Claims     map[string]string `yaml:"claims,omitempty"`
+}
+
+// validate checks the auth configuration and assigns default values
+func (a *Auth) validate() error {
+	if a.Type != AuthOAuth2 {
+		return fmt.Errorf("unknown type %q. only %s is supported", a.Type, AuthOAuth2)
+	}
+	if len(a.TokenURL) == 0 {
+		return fmt.Errorf("tokenUrl is required")
+	}
+
+	a.GrantType = stringValue(a.GrantType, GrantClientCredentials)
+	switch a.GrantType {
+	case GrantClientCredentials:
+		if len(a.ClientID) == 0 {
+			return fmt.Errorf("clientId is required for the %s grant", a.GrantType)
+		}
+	case GrantPassword:
+		if len(a.Username) == 0 {
+			return fmt.Errorf("username is required for the %s grant", a.GrantType)
+		}
+	case GrantRefreshToken:
+		if len(a.RefreshToken) == 0 {
+			return fmt.Errorf("refreshToken is required for the %s grant", a.GrantType)
+		}
+	case GrantJWTBearer:
+		if len(a.Assertion) == 0 && a.JWT == nil {
+			return fmt.Errorf("assertion or jwt is required for the %s grant", a.GrantType)
+		}
+	default:
+		return fmt.Errorf("unknown grantType %q. supported: %s, %s, %s, %s", a.GrantType, GrantClientCredentials, GrantPassword, GrantRefreshToken, GrantJWTBearer)
+	}
+
+	a.ClientAuth = stringValue(a.ClientAuth, "body")
+	if a.ClientAuth != "body" && a.ClientAuth != "basic" {
+		return fmt.Errorf("unknown clientAuth %q. supported: body, basic", a.ClientAuth)
+	}
+
+	return nil
+}
+
+// key identifies the credential set of the auth configuration
+func (a *Auth) key() string {
+	data, _ := json.Marshal(a)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenForm returns the form of a token request. With a refresh token, the
+// token is refreshed instead of using the configured grant.
+func (a *Auth) tokenForm(refreshToken string) (url.Values, error) {
+	form := url.Values{}
+
+	switch grantType := a.GrantType; {
+	case len(refreshToken) > 0 || grantType == GrantRefreshToken:
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", stringValue(refreshToken, a.RefreshToken))
+	case grantType == GrantClientCredentials:
+		form.Set("grant_type", "client_credentials")
+	case grantType == GrantPassword:
+		form.Set("grant_type", "password")
+		form.Set("username", a.Username)
+		form.Set("password", a.Password)
+	case grantType == GrantJWTBearer:
+		assertion := a.Assertion
+		if a.JWT != nil {
+			args := []string{a.JWT.Algorithm, a.JWT.Key, a.JWT.Passphrase}
+			for _, name := range sortedKeys(a.JWT.Claims) {
+				args = append(args, name+"="+a.JWT.Claims[name])
+			}
+			jwt, err := functions.SignJWT(map[string]string{}, args)
+			if err != nil {
+				return nil, err
+			}
+			assertion = jwt
+		}
+		form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+		form.Set("assertion", assertion)
+	}
+
+	if len(a.Scopes) > 0 {
+		form.Set("scope", strings.Join(a.Scopes, " "))
+	}
+	if len(a.Audience) > 0 {
+		form.Set("audience", a.Audience)
+	}
+	if a.ClientAuth == "body" && len(a.ClientID) > 0 {
+		form.Set("client_id", a.ClientID)
+		if len(a.ClientSecret) > 0 {
+			form.Set("client_secret", a.ClientSecret)
+		}
+	}
+
+	return form, nil
+}
+
+// oauth2Token is an access token from a token endpoint
+type oauth2Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+
+	expiry time.Time
+}
+
+// valid reports whether the token can still be used, leaving a margin before it expires
+func (t *oauth2Token) valid(now time.Time) bool {
+	return t != nil && (t.expiry.IsZero() || now.Add(tokenRefreshMargin).Before(t.expiry))
+}
+
+// authorization returns the value of the Authorization header
+func (t *oauth2Token) authorization() string {
+	tokenType := t.TokenType
+	if len(tokenType) == 0 || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	return tokenType + " " + t.AccessToken
+}
+
+// fetchOAuth2Token requests a token from the token endpoint
+func fetchOAuth2Token(ctx context.Context, auth *Auth, refreshToken string, config *Config) (*oauth2Token, error) {
+	form, err := auth.tokenForm(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	reqConfig := &HTTPRequestConfig{
+		Context: ctx,
+		Method:  "POST",
+		URL:     auth.TokenURL,
+		Headers: map[string]string{
+			"content-type": "application/x-www-form-urlencoded",
+			"accept":       "application/json",
+		},
+		Body:           strings.NewReader(form.Encode()),
+		TimeoutSeconds: 30,
+		Proxy:          config.Proxy,
+		ProxyUsername:  config.ProxyUsername,
+		ProxyPassword:  config.ProxyPassword,
+	}
+	if auth.ClientAuth == "basic" {
+		reqConfig.BasicAuthUsername = url.QueryEscape(auth.ClientID)
+		reqConfig.BasicAuthPassword = url.QueryEscape(auth.ClientSecret)
+	}
+	if config.har != nil {
+		reqConfig.WrapTransport = func(transport http.RoundTripper) http.RoundTripper {
+			return config.har.transport(transport, "")
+		}
+	}
+
+	zap.L().Info("requesting oauth2 token",
+		zap.String("url", config.Redactor.URL(auth.TokenURL)),
+		zap.String("grant_type", form.Get("grant_type")),
+	)
+
+	now := time.Now()
+	resp, body, err := SendHTTPRequest(reqConfig)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %s: %s", resp.Status, config.Redactor.Body(resp.Header.Get("Content-Type"), body))
+	}
+
+	token := &oauth2Token{}
+	if err := json.Unmarshal(body, token); err != nil {
+		return nil, fmt.Errorf("invalid token response: %s", err)
+	}
+	if len(token.AccessToken) == 0 {
+		return nil, fmt.Errorf("token response has no access_token")
+	}
+	if token.ExpiresIn > 0 {
+		token.expiry = now.Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+
+	// Keep using the refresh token if a new one isn't issued
+	token.RefreshToken = stringValue(token.RefreshToken, refreshToken)
+
+	return token, nil
+}
+
+// tokenCache holds access tokens for the run, per credential set
+type tokenCache struct {
+	mux     sync.Mutex
+	entries map[string]*tokenCacheEntry
+	fetch   func(ctx context.Context, auth *Auth, refreshToken string, config *Config) (*oauth2Token, error)
+	now     func() time.Time
+}
+
+type tokenCacheEntry struct {
+	// Held while fetching, so concurrent tests wait for one token request
+	mux   sync.Mutex
+	token *oauth2Token
+}
+
+func newTokenCache() *tokenCache {
+	return &tokenCache{
+		entries: map[string]*tokenCacheEntry{},
+		fetch:   fetchOAuth2Token,
+		now:     time.Now,
+	}
+}
+
+// authorization returns the Authorization header for an auth configuration,
+// fetching a token if none is cached or the cached token is about to expire
+func (c *tokenCache) authorization(ctx context.Context, auth *Auth, config *Config) (string, error) {
+	c.mux.Lock()
+	key := auth.key()
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &tokenCacheEntry{}
+		c.entries[key] = entry
+	}
+	c.mux.Unlock()
+
+	entry.mux.Lock()
+	defer entry.mux.Unlock()
+
+	if entry.token.valid(c.now()) {
+		return entry.token.authorization(), nil
+	}
+
+	// Refresh an expiring token if possible, or else request a new one
+	var token *oauth2Token
+	var err error
+	if entry.token != nil && len(entry.token.RefreshToken) > 0 {
+		token, err = c.fetch(ctx, auth, entry.token.RefreshToken, config)
+		if err != nil {
+			zap.L().Info("unable to refresh oauth2 token", zap.Error(err))
+		}
+	}
+	if token == nil {
+		token, err = c.fetch(ctx, auth, "", config)
+		if err != nil {
+			return "", fmt.Errorf("unable to get oauth2 token: %s", err)
+		}
+	}
+
+	entry.token = token
+	return token.authorization(), nil
+}
@@ -0,0 +1,208 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAuthValidate(t *testing.T) {
+	var tests = []struct {
+		auth  Auth
+		valid bool
+	}{
+		{Auth{Type: AuthOAuth2, TokenURL: "https://idp/token", ClientID: "id"}, true},
+		{Auth{Type: AuthOAuth2, TokenURL: "https://idp/token", GrantType: GrantPassword, Username: "user"}, true},
+		{Auth{Type: AuthOAuth2, TokenURL: "https://idp/token", GrantType: GrantRefreshToken, RefreshToken: "r"}, true},
+		{Auth{Type: AuthOAuth2, TokenURL: "https://idp/token", GrantType: GrantJWTBearer, Assertion: "a"}, true},
+		{Auth{Type: AuthOAuth2, TokenURL: "https://idp/token", ClientID: "id", ClientAuth: "basic"}, true},
+		{Auth{Type: "basic", TokenURL: "https://idp/token", ClientID: "id"}, false},
+		{Auth{Type: AuthOAuth2, ClientID: "id"}, false},
+		{Auth{Type: AuthOAuth2, TokenURL: "https://idp/token"}, false},
+		{Auth{Type: AuthOAuth2, TokenURL: "https://idp/token", GrantType: GrantJWTBearer}, false},
+		{Auth{Type: AuthOAuth2, TokenURL: "https://idp/token", GrantType: "implicit"}, false},
+		{Auth{Type: AuthOAuth2, TokenURL: "https://idp/token", ClientID: "id", ClientAuth: "jwt"}, false},
+	}
+
+	for _, tc := range tests {
+		if err := tc.auth.validate(); (err == nil) != tc.valid {
+			t.Errorf("validate(%+v): expected valid %t, actual error %v", tc.auth, tc.valid, err)
+		}
+	}
+}
+
+func TestAuthTokenForm(t *testing.T) {
+	var tests = []struct {
+		auth         Auth
+		refreshToken string
+		expected     string
+	}{
+		{
+			Auth{GrantType: GrantClientCredentials, ClientID: "id", ClientSecret: "secret", ClientAuth: "body", Scopes: []string{"read", "write"}, Audience: "api"},
+			"", "audience=api&client_id=id&client_secret=secret&grant_type=client_credentials&scope=read+write",
+		},
+		{
+			Auth{GrantType: GrantClientCredentials, ClientID: "id", ClientSecret: "secret", ClientAuth: "basic"},
+			"", "grant_type=client_credentials",
+		},
+		{
+			Auth{GrantType: GrantPassword, Username: "user", Password: "pass", ClientAuth: "body"},
+			"", "grant_type=password&password=pass&username=user",
+		},
+		{
+			Auth{GrantType: GrantPassword, Username: "user", Password: "pass", ClientAuth: "body"},
+			"r1", "grant_type=refresh_token&refresh_token=r1",
+		},
+		{
+			Auth{GrantType: GrantRefreshToken, RefreshToken: "r0", ClientAuth: "body"},
+			"", "grant_type=refresh_token&refresh_token=r0",
+		},
+		{
+			Auth{GrantType: GrantJWTBearer, Assertion: "a.b.c", ClientAuth: "body"},
+			"", "assertion=a.b.c&grant_type=urn%3Aietf%3Aparams%3Aoauth%3Agrant-type%3Ajwt-bearer",
+		},
+	}
+
+	for _, tc := range tests {
+		form, err := tc.auth.tokenForm(tc.refreshToken)
+		if err != nil {
+			t.Errorf("tokenForm(%+v): unexpected error %v", tc.auth, err)
+			continue
+		}
+		if actual := form.Encode(); actual != tc.expected {
+			t.Errorf("tokenForm(%+v): expected %s, actual %s", tc.auth, tc.expected, actual)
+		}
+	}
+}
+
+func TestTokenCache(t *testing.T) {
+	var mux sync.Mutex
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		user, pass, _ := r.BasicAuth()
+
+		mux.Lock()
+		requests = append(requests, r.Form.Get("grant_type"))
+		n := len(requests)
+		mux.Unlock()
+
+		if user != "id" || pass != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token%d","token_type":"bearer","expires_in":60,"refresh_token":"refresh%d"}`, n, n)
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	cache := newTokenCache()
+	cache.now = func() time.Time { return now }
+
+	auth := &Auth{Type: AuthOAuth2, TokenURL: server.URL, ClientID: "id", ClientSecret: "s3cret", ClientAuth: "basic"}
+	if err := auth.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Concurrent tests share one token request
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			authorization, err := cache.authorization(context.Background(), auth, &Config{})
+			if err != nil || authorization != "Bearer token1" {
+				t.Errorf("authorization: expected Bearer token1, actual %q %v", authorization, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The token is refreshed before it expires
+	now = now.Add(45 * time.Second)
+	authorization, err := cache.authorization(context.Background(), auth, &Config{})
+	if err != nil || authorization != "Bearer token2" {
+		t.Errorf("authorization: expected Bearer token2, actual %q %v", authorization, err)
+	}
+
+	// Other credentials get their own token
+	other := *auth
+	other.ClientSecret = "wrong"
+	if _, err := cache.authorization(context.Background(), &other, &Config{}); err == nil {
+		t.Errorf("authorization: expected error for rejected credentials")
+	}
+
+	expected := []string{"client_credentials", "refresh_token", "client_credentials"}
+	if fmt.Sprint(requests) != fmt.Sprint(expected) {
+		t.Errorf("authorization: expected token requests %v, actual %v", expected, requests)
+	}
+}
+
+func TestTokenCacheScope(t *testing.T) {
+	requests := 0
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-block
+			return
+		}
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token%d","expires_in":60}`, requests)
+	}))
+	defer server.Close()
+	defer close(block)
+
+	auth := &Auth{Type: AuthOAuth2, TokenURL: server.URL, ClientID: "id"}
+	if err := auth.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each config has its own tokens
+	for i, expected := range []string{"Bearer token1", "Bearer token2"} {
+		config := &Config{}
+		if err := ApplyConfig(config); err != nil {
+			t.Fatal(err)
+		}
+		authorization, err := config.tokens.authorization(context.Background(), auth, config)
+		if err != nil || authorization != expected {
+			t.Errorf("authorization %d: expected %s, actual %q %v", i, expected, authorization, err)
+		}
+	}
+
+	// Token requests stop when the run is cancelled
+	slow := *auth
+	slow.TokenURL = server.URL + "/slow"
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := newTokenCache().authorization(ctx, &slow, &Config{}); err == nil {
+		t.Errorf("authorization: expected error for cancelled run")
+	}
+}
+
+func TestPreProcessTestAuthSigning(t *testing.T) {
+	var tests = []struct {
+		signing *Signing
+		valid   bool
+	}{
+		{nil, true},
+		{&Signing{Type: SigningHTTPSignature, Algorithm: httpSigEd25519, Key: "k"}, true},
+		{&Signing{Type: SigningAWSSigV4, Region: "us-east-1", Service: "execute-api"}, false},
+	}
+
+	for _, tc := range tests {
+		test := &Test{}
+		test.Request.Path = "/"
+		test.Request.Auth = &Auth{Type: AuthOAuth2, TokenURL: "https://auth.example.com/token", ClientID: "id"}
+		test.Request.Signing = tc.signing
+
+		if err := preProcessTest(test, "example.com"); (err == nil) != tc.valid {
+			t.Errorf("preProcessTest(%+v): expected valid %t, actual error %v", tc.signing, tc.valid, err)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+)
+
+// openAPIContract validates test requests and responses against an OpenAPI 3 specification
+type openAPIContract struct {
+	router routers.Router
+
+	// Base paths of the servers in the spec, longest first
+	basePaths []string
+
+	// Operations ("METHOD /path/template") and the number of tests matching each
+	operations map[string]int
+	mux        sync.Mutex
+}
+
+// loadOpenAPIContract loads and validates an OpenAPI 3 specification file
+func loadOpenAPIContract(path string) (*openAPIContract, error) {
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = true
+
+	doc, err := loader.LoadFromFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load OpenAPI spec %s: %s", path, err)
+	}
+
+	// Tests may target any host, so match on paths alone after stripping server base paths
+	basePaths := []string{""}
+	for _, server := range doc.Servers {
+		if u, err := url.Parse(server.URL); err == nil && len(strings.Trim(u.Path, "/")) > 0 {
+			basePaths = append(basePaths, "/"+strings.Trim(u.Path, "/"))
+		}
+	}
+	sort.Slice(basePaths, func(i, j int) bool { return len(basePaths[i]) > len(basePaths[j]) })
+	doc.Servers = nil
+
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI spec %s: %s", path, err)
+	}
+
+	operations := map[string]int{}
+	for path, pathItem := range doc.Paths.Map() {
+		for method := range pathItem.Operations() {
+			operations[operationName(method, path)] = 0
+		}
+	}
+
+	return &openAPIContract{
+		router:     router,
+		basePaths:  basePaths,
+		operations: operations,
+	}, nil
+}
+
+func operationName(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+// validate matches a test request to an operation and validates the request and response against it
+func (c *openAPIContract) validate(test *Test, requestURL string, response *http.Response, body []byte) []error {
+	request, route, pathParams, err := c.findRoute(test, requestURL)
+	if err != nil {
+		return []error{err}
+	}
+
+	c.mux.Lock()
+	c.operations[operationName(route.Method, route.Path)]++
+	c.mux.Unlock()
+
+	options := &openapi3filter.Options{
+		IncludeResponseStatus: true,
+		MultiError:            true,
+		AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+	}
+	options.WithCustomSchemaErrorFunc(func(err *openapi3.SchemaError) string {
+		return fmt.Sprintf("at \"%s\": %s", jsonPointer(err.JSONPointer()), err.Reason)
+	})
+
+	requestInput := &openapi3filter.RequestValidationInput{
+		Request:    request,
+		PathParams: pathParams,
+		Route:      route,
+		Options:    options,
+	}
+
+	errs := []error{}
+	operation := operationName(route.Method, route.Path)
+
+	if err := openapi3filter.ValidateRequest(context.Background(), requestInput); err != nil {
+		for _, e := range flattenOpenAPIErrors(err) {
+			errs = append(errs, fmt.Errorf("request does not match OpenAPI operation %s: %s", operation, e))
+		}
+	}
+
+	responseInput := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: requestInput,
+		Status:                 response.StatusCode,
+		Header:                 response.Header,
+		Options:                options,
+	}
+	responseInput.SetBodyBytes(body)
+
+	if err := openapi3filter.ValidateResponse(context.Background(), responseInput); err != nil {
+		for _, e := range flattenOpenAPIErrors(err) {
+			errs = append(errs, fmt.Errorf("response does not match OpenAPI operation %s: %s", operation, e))
+		}
+	}
+
+	return errs
+}
+
+// findRoute builds the test request and finds the operation it matches
+func (c *openAPIContract) findRoute(test *Test, requestURL string) (*http.Request, *routers.Route, map[string]string, error) {
+	for _, basePath := range c.basePaths {
+		request, err := http.NewRequest(test.Request.Method, requestURL, bytes.NewReader([]byte(test.Request.Body)))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for k, v := range test.Request.Headers {
+			request.Header.Set(k, v)
+		}
+
+		if !strings.HasPrefix(request.URL.Path, basePath+"/") {
+			continue
+		}
+		request.URL.Path = strings.TrimPrefix(request.URL.Path, basePath)
+		request.URL.RawPath = ""
+
+		route, pathParams, err := c.router.FindRoute(request)
+		if err == nil {
+			return request, route, pathParams, nil
+		}
+	}
+
+	return nil, nil, nil, fmt.Errorf("no OpenAPI operation matches %s %s", test.Request.Method, test.Request.Path)
+}
+
+// coverage returns the operations no test has matched, sorted by path and method, and the total number of operations
+func (c *openAPIContract) coverage() ([]string, int) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	untested := []string{}
+	for operation, count := range c.operations {
+		if count == 0 {
+			untested = append(untested, operation)
+		}
+	}
+	sort.Slice(untested, func(i, j int) bool {
+		pi, pj := strings.SplitN(untested[i], " ", 2)[1], strings.SplitN(untested[j], " ", 2)[1]
+		if pi != pj {
+			return pi < pj
+		}
+		return untested[i] < untested[j]
+	})
+	return untested, len(c.operations)
+}
+
+// flattenOpenAPIErrors splits the multi-errors returned by validation into individual errors
+func flattenOpenAPIErrors(err error) []error {
+	var multi openapi3.MultiError
+
+	// Response errors wrap the individual body or header errors; keep their reason as a prefix
+	var responseErr *openapi3filter.ResponseError
+	if errors.As(err, &responseErr) && responseErr.Err != nil && errors.As(responseErr.Err, &multi) {
+		errs := []error{}
+		for _, e := range flattenOpenAPIErrors(responseErr.Err) {
+			errs = append(errs, fmt.Errorf("%s: %s", responseErr.Reason, e))
+		}
+		return errs
+	}
+
+	if errors.As(err, &multi) {
+		errs := []error{}
+		for _, e := range multi {
+			errs = append(errs, flattenOpenAPIErrors(e)...)
+		}
+		return errs
+	}
+
+	return []error{err}
+}
@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testOpenAPISpec = `
+openapi: 3.0.3
+info:
+  title: Test
+  version: '1'
+servers:
+  - url: https://api.example.com/v1
+paths:
+  /users/{id}:
+    get:
+      parameters:
+        - name: id
+          in: path
+          required: true
+          schema:
+            type: integer
+      responses:
+        '200':
+          description: user
+          headers:
+            x-request-id:
+              required: true
+              schema:
+                type: string
+          content:
+            application/json:
+              schema:
+                type: object
+                required: [id]
+                properties:
+                  id:
+                    type: integer
+    delete:
+      parameters:
+        - name: id
+          in: path
+          required: true
+          schema:
+            type: integer
+      responses:
+        '204':
+          description: deleted
+  /status:
+    get:
+      responses:
+        '200':
+          description: ok
+`
+
+func TestOpenAPIContract(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spec.yml")
+	if err := os.WriteFile(path, []byte(testOpenAPISpec), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	contract, err := loadOpenAPIContract(path)
+	if err != nil {
+		t.Fatalf("loadOpenAPIContract: unexpected error %v", err)
+	}
+
+	var tests = []struct {
+		method   string
+		path     string
+		status   int
+		headers  http.Header
+		body     string
+		expected []string
+	}{
+		{
+			"GET",
+			"/v1/users/1",
+			200,
+			http.Header{"Content-Type": {"application/json"}, "X-Request-Id": {"abc"}},
+			`{"id":1}`,
+			[]string{},
+		},
+		{
+			"GET",
+			"/v1/users/1",
+			200,
+			http.Header{"Content-Type": {"application/json"}, "X-Request-Id": {"abc"}},
+			`{"id":"1"}`,
+			[]string{`response does not match OpenAPI operation GET /users/{id}: response body doesn't match schema: at "/id": value must be an integer`},
+		},
+		{
+			"GET",
+			"/v1/users/1",
+			200,
+			http.Header{"Content-Type": {"application/json"}},
+			`{"id":1}`,
+			[]string{`response does not match OpenAPI operation GET /users/{id}: response header "x-request-id" missing`},
+		},
+		{
+			"GET",
+			"/v1/users/1",
+			404,
+			http.Header{},
+			``,
+			[]string{`response does not match OpenAPI operation GET /users/{id}: status is not supported`},
+		},
+		{
+			"GET",
+			"/v1/users/abc",
+			200,
+			http.Header{"Content-Type": {"application/json"}, "X-Request-Id": {"abc"}},
+			`{"id":1}`,
+			[]string{`request does not match OpenAPI operation GET /users/{id}: parameter "id" in path has an error`},
+		},
+		{
+			"POST",
+			"/v1/users/1",
+			200,
+			http.Header{},
+			``,
+			[]string{`no OpenAPI operation matches POST /v1/users/1`},
+		},
+	}
+
+	for _, tc := range tests {
+		test := &Test{}
+		test.Request.Method = tc.method
+		test.Request.Path = tc.path
+		response := &http.Response{StatusCode: tc.status, Header: tc.headers}
+
+		errs := contract.validate(test, "https://example.com"+tc.path, response, []byte(tc.body))
+		if len(errs) != len(tc.expected) {
+			t.Errorf("validate(%s %s): expected %v, actual %v", tc.method, tc.path, tc.expected, errs)
+			continue
+		}
+		for i, err := range errs {
+			if !strings.HasPrefix(err.Error(), tc.expected[i]) {
+				t.Errorf("validate(%s %s): expected %v, actual %v", tc.method, tc.path, tc.expected[i], err)
+			}
+		}
+	}
+
+	untested, total := contract.coverage()
+	if total != 3 || len(untested) != 2 || untested[0] != "GET /status" || untested[1] != "DELETE /users/{id}" {
+		t.Errorf("coverage: expected [GET /status DELETE /users/{id}] of 3, actual %v of %d", untested, total)
+	}
+}
@@ -20,8 +20,8 @@ import (
 	"github.com/fatih/color"
 )
 
-// PrintTestResult prints result of a single test
-func PrintTestResult(test *Test, result *TestResult) {
+// PrintTestResult prints result of a single test, with sensitive values redacted
+func PrintTestResult(test *Test, result *TestResult, redactor *Redactor) {
 	color.NoColor = false
 	fmt.Println("")
 
@@ -41,13 +41,23 @@ func PrintTestResult(test *Test, result *TestResult) {
 	}
 
 	// Print test info
-	fmt.Printf("%s\n", fmt.Sprintf("%s | %s | %s", test.Filename, test.Description, test.Request.Path))
+	fmt.Printf("%s\n", fmt.Sprintf("%s | %s | %s", test.Filename, test.Description, redactor.String(test.Request.Path)))
 
 	// Print all errors
 	if len(result.Errors) > 0 {
 		fmt.Printf("errors:\n")
 		for _, err := range result.Errors {
-			fmt.Printf("%s\n", err.Error())
+			fmt.Printf("%s\n", redactor.String(err.Error()))
+
+			// Show what was expected next to the actual response
+			if assertionErr, ok := err.(*AssertionError); ok {
+				fmt.Printf("%s\n", assertionErr.View(redactor))
+			}
+		}
+
+		// Print the request to reproduce the failure with
+		if len(result.Curl) > 0 {
+			fmt.Printf("request:\n%s\n", result.Curl)
 		}
 	}
 }
@@ -63,3 +73,13 @@ func PrintTestSummary(passed, failed, skipped int) {
 		color.HiBlueString("%d", skipped),
 	)
 }
+
+// PrintOpenAPICoverage prints the OpenAPI operations not covered by any test
+func PrintOpenAPICoverage(untested []string, total int) {
+	color.NoColor = false
+
+	fmt.Printf("\nOpenAPI coverage: %d/%d operations tested\n", total-len(untested), total)
+	for _, operation := range untested {
+		fmt.Printf("%s %s\n", color.HiYellowString("untested:"), operation)
+	}
+}
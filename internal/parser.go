@@ -17,6 +17,7 @@ package internal
 import (
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
@@ -27,38 +28,97 @@ import (
 
 // TestFile is a single test definition file
 type TestFile struct {
-	Tests []*Test `yaml:"tests"`
+	Variables []Variable `yaml:"variables,omitempty"`
+	Tests     []*Test    `yaml:"tests"`
 }
 
 // Test is a single test
 type Test struct {
-	Filename    string
-	Description string
+	Filename    string `yaml:"-"`
+	Directory   string `yaml:"-"`
+	variables   *variableScope
+	transport   http.RoundTripper
+	Description string `yaml:"description"`
 	Conditions  struct {
-		Env map[string]string `yaml:"env"`
-	} `yaml:"conditions"`
-	SkipCertVerification bool `yaml:"skipCertVerification"`
+		Env map[string]string `yaml:"env,omitempty"`
+	} `yaml:"conditions,omitempty"`
+	SkipCertVerification bool `yaml:"skipCertVerification,omitempty"`
 	Request              struct {
-		Scheme         string            `yaml:"scheme"`
-		Host           string            `yaml:"host"`
-		Method         string            `yaml:"method"`
+		Scheme         string            `yaml:"scheme,omitempty"`
+		Host           string            `yaml:"host,omitempty"`
+		Method         string            `yaml:"method,omitempty"`
 		Path           string            `yaml:"path"`
-		Headers        map[string]string `yaml:"headers"`
-		DynamicHeaders []DynamicHeader   `yaml:"dynamicHeaders"`
-		Body           string            `yaml:"body"`
+		Query          map[string]string `yaml:"query,omitempty"`
+		Headers        map[string]string `yaml:"headers,omitempty"`
+		DynamicHeaders []DynamicHeader   `yaml:"dynamicHeaders,omitempty"`
+		Body           string            `yaml:"body,omitempty"`
+		Proxy          string            `yaml:"proxy,omitempty"`
+		Signing        *Signing          `yaml:"signing,omitempty"`
+		Auth           *Auth             `yaml:"auth,omitempty"`
 	} `yaml:"request"`
 	Response struct {
-		StatusCodes []int `yaml:"statusCodes"`
+		StatusCodes []int `yaml:"statusCodes,omitempty"`
 		Headers     struct {
-			Patterns             map[string]string `yaml:"patterns"`
-			NotPresent           []string          `yaml:"notPresent"`
-			NotMatching          map[string]string `yaml:"notMatching"`
-			IfPresentNotMatching map[string]string `yaml:"ifPresentNotMatching"`
-		} `yaml:"headers"`
+			Patterns             map[string]string `yaml:"patterns,omitempty"`
+			NotPresent           []string          `yaml:"notPresent,omitempty"`
+			NotMatching          map[string]string `yaml:"notMatching,omitempty"`
+			IfPresentNotMatching map[string]string `yaml:"ifPresentNotMatching,omitempty"`
+		} `yaml:"headers,omitempty"`
 		Body struct {
-			Patterns []string `yaml:"patterns"`
-		}
-	} `yaml:"response"`
+			Patterns []string    `yaml:"patterns,omitempty"`
+			Snapshot *Snapshot   `yaml:"snapshot,omitempty"`
+			Schema   *JSONSchema `yaml:"schema,omitempty"`
+		} `yaml:"body,omitempty"`
+		Assertions []CustomAssertion `yaml:"assertions,omitempty"`
+	} `yaml:"response,omitempty"`
+	Mock *Mock `yaml:"mock,omitempty"`
+	Diff *Diff `yaml:"diff,omitempty"`
+}
+
+// clone returns a copy of a test to run, leaving the test unchanged
+func (t *Test) clone() *Test {
+	c := *t
+	c.Conditions.Env = copyStringMap(t.Conditions.Env)
+	c.Request.Query = copyStringMap(t.Request.Query)
+	c.Request.Headers = copyStringMap(t.Request.Headers)
+	c.Request.DynamicHeaders = nil
+	for _, dynamicHeader := range t.Request.DynamicHeaders {
+		dynamicHeader.Args = append([]string(nil), dynamicHeader.Args...)
+		c.Request.DynamicHeaders = append(c.Request.DynamicHeaders, dynamicHeader)
+	}
+	if t.Request.Signing != nil {
+		signing := *t.Request.Signing
+		c.Request.Signing = &signing
+	}
+	if t.Request.Auth != nil {
+		auth := *t.Request.Auth
+		c.Request.Auth = &auth
+	}
+
+	c.Response.StatusCodes = append([]int(nil), t.Response.StatusCodes...)
+	c.Response.Headers.Patterns = copyStringMap(t.Response.Headers.Patterns)
+	c.Response.Headers.NotPresent = append([]string(nil), t.Response.Headers.NotPresent...)
+	c.Response.Headers.NotMatching = copyStringMap(t.Response.Headers.NotMatching)
+	c.Response.Headers.IfPresentNotMatching = copyStringMap(t.Response.Headers.IfPresentNotMatching)
+	c.Response.Body.Patterns = append([]string(nil), t.Response.Body.Patterns...)
+	c.Response.Assertions = nil
+	for _, assertion := range t.Response.Assertions {
+		assertion.Args = append([]string(nil), assertion.Args...)
+		c.Response.Assertions = append(c.Response.Assertions, assertion)
+	}
+
+	return &c
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
 }
 
 type DynamicHeader struct {
@@ -79,8 +139,11 @@ func ParseAllTestsInDirectory(root string) ([]*Test, error) {
 			return nil
 		}
 
-		// Skip directories
+		// Skip directories, and snapshot directories entirely
 		if info.IsDir() {
+			if info.Name() == snapshotDirectory {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
@@ -131,8 +194,15 @@ func parseTestFile(filePath string) ([]*Test, error) {
 
 	// Add file path to tests
 	fileName := path.Base(filePath)
+	directory := filepath.Dir(filePath)
+	var variables *variableScope
+	if len(tf.Variables) > 0 {
+		variables = &variableScope{name: fileName, variables: tf.Variables}
+	}
 	for _, test := range tf.Tests {
 		test.Filename = fileName
+		test.Directory = directory
+		test.variables = variables
 	}
 
 	return tf.Tests, nil
@@ -0,0 +1,75 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ProxyDirect disables proxying, including proxies set by HTTP_PROXY and HTTPS_PROXY
+const ProxyDirect = "direct"
+
+// validateProxy checks that a proxy setting is empty, ProxyDirect or a supported proxy URL
+func validateProxy(proxy string) error {
+	if len(proxy) == 0 || proxy == ProxyDirect {
+		return nil
+	}
+
+	_, err := parseProxyURL(proxy, "", "")
+	return err
+}
+
+// parseProxyURL parses a proxy URL, adding credentials if the URL doesn't carry its own
+func parseProxyURL(proxy, username, password string) (*url.URL, error) {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q. only http, https, socks5 and socks5h are supported", u.Scheme)
+	}
+
+	if len(u.Host) == 0 {
+		return nil, fmt.Errorf("proxy %q has no host", proxy)
+	}
+
+	if u.User == nil && (len(username) > 0 || len(password) > 0) {
+		u.User = url.UserPassword(username, password)
+	}
+
+	return u, nil
+}
+
+// proxyFunc returns the proxy selection function for a transport.
+// An empty proxy falls back to HTTP_PROXY, HTTPS_PROXY and NO_PROXY from the environment.
+func proxyFunc(proxy, username, password string) (func(*http.Request) (*url.URL, error), error) {
+	switch proxy {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case ProxyDirect:
+		return nil, nil
+	}
+
+	u, err := parseProxyURL(proxy, username, password)
+	if err != nil {
+		return nil, err
+	}
+	return http.ProxyURL(u), nil
+}
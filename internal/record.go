@@ -0,0 +1,223 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultRecordHeaders are the response headers recorded tests assert on by default
+var DefaultRecordHeaders = []string{"content-type", "cache-control", "vary"}
+
+// RecordOptions controls how proxied traffic is recorded as tests
+type RecordOptions struct {
+	ImportOptions
+	Snapshots bool // Compare response bodies with snapshots
+}
+
+// Recorder is a reverse proxy to a target host that records the requests and
+// responses passing through it as tests
+type Recorder struct {
+	proxy  *httputil.ReverseProxy
+	target *url.URL
+	opts   RecordOptions
+	log    io.Writer
+
+	mux       sync.Mutex
+	tests     []*Test
+	snapshots map[string]string
+}
+
+type recordContextKey struct{}
+
+// recordedRequest is the request received by the proxy
+type recordedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// NewRecorder returns a recorder for a target URL. Recorded requests are logged to log.
+func NewRecorder(target string, opts RecordOptions, log io.Writer) (*Recorder, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return nil, fmt.Errorf("unsupported target URL %q", target)
+	}
+
+	r := &Recorder{target: u, opts: opts, log: log, snapshots: map[string]string{}}
+	r.proxy = &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(u)
+
+			// Uncompressed bodies can be compared with snapshots
+			pr.Out.Header.Del("Accept-Encoding")
+		},
+		ModifyResponse: r.record,
+	}
+
+	return r, nil
+}
+
+func (r *Recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	recorded := &recordedRequest{header: req.Header.Clone(), body: body}
+	r.proxy.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), recordContextKey{}, recorded)))
+}
+
+// record adds a test for a response of the target
+func (r *Recorder) record(resp *http.Response) error {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	recorded, _ := resp.Request.Context().Value(recordContextKey{}).(*recordedRequest)
+	if recorded == nil {
+		return nil
+	}
+
+	// Tests are run against TEST_HOST
+	test, err := newImportedTest(resp.Request.Method, resp.Request.URL.String())
+	if err != nil {
+		return err
+	}
+	test.Request.Host = ""
+	for name := range recorded.header {
+		if !r.opts.stripped(name) {
+			test.Request.Headers[strings.ToLower(name)] = recorded.header.Get(name)
+		}
+	}
+	test.Request.Body = string(recorded.body)
+
+	test.Response.StatusCodes = []int{resp.StatusCode}
+	test.Response.Headers.Patterns = map[string]string{}
+	for name := range resp.Header {
+		name = strings.ToLower(name)
+		if !r.opts.stripped(name) && containsFold(r.opts.AssertHeaders, name) {
+			test.Response.Headers.Patterns[name] = exactPattern(resp.Header.Get(name))
+		}
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	fmt.Fprintf(r.log, "%s %s -> %d\n", test.Request.Method, test.Request.Path, resp.StatusCode)
+	r.add(test, body)
+
+	return nil
+}
+
+// add records a test, merging it with an earlier test of the same request.
+// Only the header values of all responses to a request are asserted on.
+func (r *Recorder) add(test *Test, body []byte) {
+	for _, earlier := range r.tests {
+		if earlier.Request.Method != test.Request.Method || earlier.Request.Path != test.Request.Path || earlier.Request.Body != test.Request.Body {
+			continue
+		}
+
+		if earlier.Response.StatusCodes[0] != test.Response.StatusCodes[0] {
+			// Responses that differ in status are recorded separately
+			continue
+		}
+		for name, pattern := range earlier.Response.Headers.Patterns {
+			if test.Response.Headers.Patterns[name] != pattern {
+				delete(earlier.Response.Headers.Patterns, name)
+			}
+		}
+
+		// Only the first body is kept as a snapshot
+		return
+	}
+
+	if r.opts.Snapshots && len(body) > 0 {
+		r.addSnapshot(test, body)
+	}
+	r.tests = append(r.tests, test)
+}
+
+// nonAlphanumeric matches runs of characters left out of snapshot names
+var nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+
+// addSnapshot compares the body of a test with a snapshot of the recorded body
+func (r *Recorder) addSnapshot(test *Test, body []byte) {
+	base := strings.Trim(nonAlphanumeric.ReplaceAllString(strings.ToLower(test.Description), "-"), "-")
+	name := base
+	for i := 2; len(r.snapshots[name]) > 0; i++ {
+		name = base + "-" + strconv.Itoa(i)
+	}
+
+	snapshot := &Snapshot{Name: name, JSON: true}
+	content, err := snapshot.normalize(body)
+	if err != nil {
+		// Not JSON
+		snapshot.JSON = false
+		content = string(body)
+	}
+
+	test.Response.Body.Snapshot = snapshot
+	r.snapshots[name] = content
+}
+
+// TestFile returns the recorded tests
+func (r *Recorder) TestFile() *TestFile {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	return &TestFile{Tests: append([]*Test{}, r.tests...)}
+}
+
+// WriteSnapshots writes the recorded snapshots beside a test file in directory
+func (r *Recorder) WriteSnapshots(directory string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	names := []string{}
+	for name := range r.snapshots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path, err := snapshotPath(directory, name)
+		if err != nil {
+			return err
+		}
+		if err := writeSnapshot(path, r.snapshots[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
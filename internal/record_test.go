@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	requests := 0
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("x-request-id", strings.Repeat("a", requests))
+		switch r.URL.Path {
+		case "/api/orders":
+			// Differs between responses
+			w.Header().Set("cache-control", "max-age="+strings.Repeat("1", requests))
+			w.Header().Set("content-type", "application/json")
+			w.Write([]byte(`{"orders":[{"id":1}]}`))
+		case "/api/echo":
+			w.Header().Set("content-type", "text/plain")
+			io.Copy(w, r.Body)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer target.Close()
+
+	var log bytes.Buffer
+	recorder, err := NewRecorder(target.URL+"/api", RecordOptions{
+		ImportOptions: ImportOptions{StripHeaders: DefaultStripHeaders, AssertHeaders: DefaultRecordHeaders},
+		Snapshots:     true,
+	}, &log)
+	if err != nil {
+		t.Fatalf("NewRecorder: unexpected error %v", err)
+	}
+	proxy := httptest.NewServer(recorder)
+	defer proxy.Close()
+
+	for _, path := range []string{"/orders", "/orders", "/missing"} {
+		resp, err := http.Get(proxy.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	req, _ := http.NewRequest("POST", proxy.URL+"/echo", strings.NewReader("hello"))
+	req.Header.Set("x-client", "qa")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "hello" {
+		t.Errorf("proxy: expected body hello, actual %s", body)
+	}
+
+	tests := recorder.TestFile().Tests
+	if len(tests) != 3 {
+		t.Fatalf("TestFile: expected 3 tests, actual %d", len(tests))
+	}
+
+	orders := tests[0]
+	if orders.Request.Path != "/api/orders" || orders.Request.Host != "" || orders.Request.Scheme != "http" {
+		t.Errorf("TestFile: unexpected request %+v", orders.Request)
+	}
+	patterns := orders.Response.Headers.Patterns
+	if len(patterns) != 1 || patterns["content-type"] != "^application/json$" {
+		t.Errorf("TestFile: expected only stable headers, actual %v", patterns)
+	}
+	if orders.Response.Body.Snapshot == nil || orders.Response.Body.Snapshot.Name != "get-api-orders" || !orders.Response.Body.Snapshot.JSON {
+		t.Errorf("TestFile: unexpected snapshot %+v", orders.Response.Body.Snapshot)
+	}
+
+	if tests[1].Response.StatusCodes[0] != 404 || tests[1].Response.Body.Snapshot != nil {
+		t.Errorf("TestFile: unexpected missing test %+v", tests[1].Response)
+	}
+
+	echo := tests[2]
+	if echo.Request.Body != "hello" || echo.Request.Headers["x-client"] != "qa" || echo.Response.Body.Snapshot.JSON {
+		t.Errorf("TestFile: unexpected echo test %+v", echo)
+	}
+
+	dir := t.TempDir()
+	if err := recorder.WriteSnapshots(dir); err != nil {
+		t.Fatalf("WriteSnapshots: unexpected error %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, snapshotDirectory, "get-api-orders.snap"))
+	if err != nil || !strings.Contains(string(data), `"id": 1`) {
+		t.Errorf("WriteSnapshots: unexpected snapshot %s %v", data, err)
+	}
+
+	if !strings.Contains(log.String(), "GET /api/orders -> 200") {
+		t.Errorf("record: unexpected log %s", log.String())
+	}
+}
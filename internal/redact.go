@@ -0,0 +1,308 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultRedactHeaders are the headers whose values are always redacted
+var DefaultRedactHeaders = []string{
+	"authorization",
+	"proxy-authorization",
+	"cookie",
+	"set-cookie",
+	"x-api-key",
+	"x-auth-token",
+	"x-amz-security-token",
+}
+
+// DefaultRedactFields are the JSON and form fields whose values are always redacted
+var DefaultRedactFields = []string{
+	"password",
+	"client_secret",
+	"access_token",
+	"refresh_token",
+	"id_token",
+}
+
+const redactedValue = "[REDACTED]"
+
+// Secrets shorter than this would mask unrelated text, so they are not redacted
+const minSecretLength = 4
+
+// Redactor hides sensitive values in logs, test output, curl commands and HAR files.
+// A nil Redactor leaves values unchanged.
+type Redactor struct {
+	headers []string
+	fields  []string
+	pattern *regexp.Regexp
+	secrets []string
+}
+
+// NewRedactor returns a redactor for header names, JSON and form field names, a
+// pattern of values and secret values, in addition to the defaults
+func NewRedactor(headers, fields []string, pattern string, secrets []string) (*Redactor, error) {
+	r := &Redactor{
+		headers: append(append([]string{}, DefaultRedactHeaders...), headers...),
+		fields:  append(append([]string{}, DefaultRedactFields...), fields...),
+	}
+
+	if len(pattern) > 0 {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern: %s", err)
+		}
+		r.pattern = re
+	}
+
+	for _, secret := range secrets {
+		if len(secret) >= minSecretLength {
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+
+	// Replace longer secrets first, in case one contains another
+	sort.SliceStable(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+
+	return r, nil
+}
+
+// String redacts secret values and matches of the pattern. If the pattern has
+// groups, only the groups are redacted.
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, redactedValue)
+	}
+
+	if r.pattern == nil {
+		return s
+	}
+
+	var buf strings.Builder
+	last := 0
+	for _, match := range r.pattern.FindAllStringSubmatchIndex(s, -1) {
+		// Without groups, redact the whole match
+		groups := match[2:]
+		if len(groups) == 0 {
+			groups = match[:2]
+		}
+		for i := 0; i < len(groups); i += 2 {
+			if groups[i] < last {
+				continue
+			}
+			buf.WriteString(s[last:groups[i]])
+			buf.WriteString(redactedValue)
+			last = groups[i+1]
+		}
+	}
+	buf.WriteString(s[last:])
+
+	return buf.String()
+}
+
+// Header redacts the value of a header
+func (r *Redactor) Header(name, value string) string {
+	if r == nil {
+		return value
+	}
+	if containsFold(r.headers, name) {
+		return redactedValue
+	}
+	return r.String(value)
+}
+
+// Headers returns a redacted copy of headers
+func (r *Redactor) Headers(headers map[string]string) map[string]string {
+	if r == nil {
+		return headers
+	}
+	redacted := map[string]string{}
+	for name, value := range headers {
+		redacted[name] = r.Header(name, value)
+	}
+	return redacted
+}
+
+// HTTPHeader returns a redacted copy of HTTP headers
+func (r *Redactor) HTTPHeader(header http.Header) http.Header {
+	if r == nil {
+		return header
+	}
+	redacted := http.Header{}
+	for name, values := range header {
+		for _, value := range values {
+			redacted[name] = append(redacted[name], r.Header(name, value))
+		}
+	}
+	return redacted
+}
+
+// Field reports whether the value of a JSON or form field is redacted
+func (r *Redactor) Field(name string) bool {
+	return r != nil && containsFold(r.fields, name)
+}
+
+// URL redacts query parameters, the password of a URL and other sensitive values
+func (r *Redactor) URL(rawURL string) string {
+	if r == nil {
+		return rawURL
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return r.String(rawURL)
+	}
+	if redacted, ok := r.form(u.RawQuery); ok {
+		u.RawQuery = redacted
+	}
+
+	return r.String(u.Redacted())
+}
+
+// Body redacts the fields of JSON and form bodies, and other sensitive values
+func (r *Redactor) Body(contentType string, body []byte) []byte {
+	if r == nil || len(body) == 0 {
+		return body
+	}
+
+	if strings.Contains(contentType, "x-www-form-urlencoded") {
+		if redacted, ok := r.form(string(body)); ok {
+			body = []byte(redacted)
+		}
+	} else if strings.Contains(contentType, "json") || json.Valid(body) {
+		if redacted, ok := r.json(body); ok {
+			body = redacted
+		}
+	}
+
+	return []byte(r.String(string(body)))
+}
+
+// Text redacts JSON and form fields wherever they appear in s, such as in
+// pretty-printed bodies and diffs, keeping its formatting, and other
+// sensitive values. Only scalar JSON values are redacted.
+func (r *Redactor) Text(s string) string {
+	if r == nil {
+		return s
+	}
+
+	if len(r.fields) > 0 {
+		names := make([]string, len(r.fields))
+		for i, field := range r.fields {
+			names[i] = regexp.QuoteMeta(field)
+		}
+		alternatives := strings.Join(names, "|")
+
+		jsonField := regexp.MustCompile(`(?i)("(?:` + alternatives + `)"\s*:\s*)("(?:[^"\\]|\\.)*"|[^\s,{}\[\]]+)`)
+		s = jsonField.ReplaceAllString(s, `${1}"`+redactedValue+`"`)
+
+		formField := regexp.MustCompile(`(?i)((?:^|[?&\s])(?:` + alternatives + `)=)[^&\s]*`)
+		s = formField.ReplaceAllString(s, `${1}`+redactedValue)
+	}
+
+	return r.String(s)
+}
+
+// form redacts fields of a URL encoded form, returning false if there are none.
+// The order and encoding of other fields are kept.
+func (r *Redactor) form(query string) (string, bool) {
+	found := false
+	pairs := strings.Split(query, "&")
+	for i, pair := range pairs {
+		name, _, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil && r.Field(unescaped) {
+			pairs[i] = name + "=" + redactedValue
+			found = true
+		}
+	}
+	if !found {
+		return query, false
+	}
+	return strings.Join(pairs, "&"), true
+}
+
+// json redacts fields of a JSON document, returning false if there are none
+func (r *Redactor) json(body []byte) ([]byte, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return body, false
+	}
+	if !r.redactJSONValue(value) {
+		return body, false
+	}
+
+	redacted, err := json.Marshal(value)
+	if err != nil {
+		return body, false
+	}
+	return redacted, true
+}
+
+func (r *Redactor) redactJSONValue(value interface{}) bool {
+	found := false
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for name, child := range v {
+			if r.Field(name) {
+				v[name] = redactedValue
+				found = true
+			} else if r.redactJSONValue(child) {
+				found = true
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if r.redactJSONValue(child) {
+				found = true
+			}
+		}
+	}
+	return found
+}
+
+// Writer returns a writer that redacts secret values and pattern matches
+// before writing to w. Each write is redacted separately, so this suits
+// writers of whole lines such as loggers.
+func (r *Redactor) Writer(w io.Writer) io.Writer {
+	return &redactWriter{redactor: r, w: w}
+}
+
+type redactWriter struct {
+	redactor *Redactor
+	w        io.Writer
+}
+
+func (w *redactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, w.redactor.String(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,197 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// inlineSchemaName is the file name inline schemas are compiled under, so relative references resolve beside the test file
+const inlineSchemaName = "inline-schema.json"
+
+var schemaMessages = message.NewPrinter(language.English)
+
+// Compiled schema files, shared by all tests
+var (
+	schemaFiles    = map[string]*jsonschema.Schema{}
+	schemaFilesMux = sync.Mutex{}
+)
+
+// JSONSchema is a JSON Schema to validate a response body against, either a file or inline
+type JSONSchema struct {
+	File   string
+	Inline interface{}
+}
+
+// UnmarshalYAML reads a schema file path from a string, or an inline schema from a mapping
+func (s *JSONSchema) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var file string
+	if err := unmarshal(&file); err == nil {
+		s.File = file
+		return nil
+	}
+
+	var inline map[interface{}]interface{}
+	if err := unmarshal(&inline); err != nil {
+		return fmt.Errorf("schema must be a file path or an inline schema: %s", err)
+	}
+
+	// Round-trip through JSON to get the value types the validator expects
+	data, err := json.Marshal(yamlToJSONValue(inline))
+	if err != nil {
+		return fmt.Errorf("invalid inline schema: %s", err)
+	}
+	s.Inline, err = jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	return err
+}
+
+// MarshalYAML writes the schema back in the form it was given
+func (s JSONSchema) MarshalYAML() (interface{}, error) {
+	if s.Inline != nil {
+		return s.Inline, nil
+	}
+	return s.File, nil
+}
+
+// compile compiles the schema, resolving files relative to the test file directory
+func (s *JSONSchema) compile(directory string) (*jsonschema.Schema, error) {
+	if s.Inline != nil {
+		location, err := filepath.Abs(filepath.Join(directory, inlineSchemaName))
+		if err != nil {
+			return nil, err
+		}
+		compiler := jsonschema.NewCompiler()
+		if err := compiler.AddResource(location, s.Inline); err != nil {
+			return nil, err
+		}
+		return compiler.Compile(location)
+	}
+
+	path := s.File
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(directory, path)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	schemaFilesMux.Lock()
+	defer schemaFilesMux.Unlock()
+
+	if schema, ok := schemaFiles[path]; ok {
+		return schema, nil
+	}
+
+	schema, err := jsonschema.NewCompiler().Compile(path)
+	if err != nil {
+		return nil, err
+	}
+	schemaFiles[path] = schema
+	return schema, nil
+}
+
+// validateSchema validates a response body against a test's JSON Schema, returning an error per violation
+func validateSchema(test *Test, body []byte) []error {
+	schema, err := test.Response.Body.Schema.compile(test.Directory)
+	if err != nil {
+		return []error{fmt.Errorf("invalid response body schema: %s", err)}
+	}
+
+	return validateJSONSchema(schema, body)
+}
+
+// validateJSONSchema validates a JSON document against a compiled schema, returning an error per violation
+func validateJSONSchema(schema *jsonschema.Schema, body []byte) []error {
+	value, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+	if err != nil {
+		return []error{fmt.Errorf("response body is not valid JSON: %s", err)}
+	}
+
+	err = schema.Validate(value)
+	if err == nil {
+		return nil
+	}
+
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return []error{err}
+	}
+
+	// Sort violations so they are reported in a stable order
+	violations := []string{}
+	for _, leaf := range schemaViolations(validationErr) {
+		violations = append(violations, fmt.Sprintf("response body violates schema at \"%s\": %s", jsonPointer(leaf.InstanceLocation), leaf.ErrorKind.LocalizedString(schemaMessages)))
+	}
+	sort.Strings(violations)
+
+	errs := []error{}
+	for _, violation := range violations {
+		errs = append(errs, errors.New(violation))
+	}
+	return errs
+}
+
+// schemaViolations flattens a validation error tree into its leaf errors
+func schemaViolations(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return []*jsonschema.ValidationError{err}
+	}
+
+	leaves := []*jsonschema.ValidationError{}
+	for _, cause := range err.Causes {
+		leaves = append(leaves, schemaViolations(cause)...)
+	}
+	return leaves
+}
+
+// jsonPointer formats a location as an RFC 6901 JSON pointer
+func jsonPointer(location []string) string {
+	var buffer strings.Builder
+	for _, token := range location {
+		buffer.WriteByte('/')
+		buffer.WriteString(strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1"))
+	}
+	return buffer.String()
+}
+
+// yamlToJSONValue converts the generic maps produced by the YAML decoder into JSON-compatible maps
+func yamlToJSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for key, val := range v {
+			m[fmt.Sprintf("%v", key)] = yamlToJSONValue(val)
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = yamlToJSONValue(v[i])
+		}
+		return v
+	}
+	return value
+}
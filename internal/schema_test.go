@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+const testSchemaYAML = `
+schema:
+  type: object
+  required: [id, name]
+  properties:
+    id:
+      type: integer
+    name:
+      type: string
+    tags:
+      type: array
+      items:
+        type: string
+`
+
+func TestValidateSchema(t *testing.T) {
+	var inline struct {
+		Schema *JSONSchema `yaml:"schema"`
+	}
+	if err := yaml.Unmarshal([]byte(testSchemaYAML), &inline); err != nil {
+		t.Fatalf("yaml.Unmarshal: unexpected error %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "item.json"), []byte(`{"type":"object","required":["id"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		schema   *JSONSchema
+		body     string
+		expected []string
+	}{
+		{
+			inline.Schema,
+			`{"id":1,"name":"a","tags":["x"]}`,
+			[]string{},
+		},
+		{
+			inline.Schema,
+			`{"id":"1","tags":["x",2]}`,
+			[]string{
+				`response body violates schema at "": missing property 'name'`,
+				`response body violates schema at "/id": got string, want integer`,
+				`response body violates schema at "/tags/1": got number, want string`,
+			},
+		},
+		{
+			inline.Schema,
+			`not json`,
+			[]string{"response body is not valid JSON: invalid character 'o' in literal null (expecting 'u')"},
+		},
+		{
+			&JSONSchema{File: "item.json"},
+			`{}`,
+			[]string{`response body violates schema at "": missing property 'id'`},
+		},
+		{
+			&JSONSchema{File: "missing.json"},
+			`{}`,
+			[]string{"invalid response body schema"},
+		},
+	}
+
+	for _, tc := range tests {
+		test := &Test{Directory: dir}
+		test.Response.Body.Schema = tc.schema
+
+		errs := validateSchema(test, []byte(tc.body))
+		if len(errs) != len(tc.expected) {
+			t.Errorf("validateSchema(%s): expected %v, actual %v", tc.body, tc.expected, errs)
+			continue
+		}
+		for i, err := range errs {
+			if len(err.Error()) < len(tc.expected[i]) || err.Error()[:len(tc.expected[i])] != tc.expected[i] {
+				t.Errorf("validateSchema(%s): expected %v, actual %v", tc.body, tc.expected[i], err)
+			}
+		}
+	}
+}
+
+func TestJSONPointer(t *testing.T) {
+	var tests = []struct {
+		location []string
+		expected string
+	}{
+		{[]string{}, ""},
+		{[]string{"a", "0"}, "/a/0"},
+		{[]string{"a/b", "c~d"}, "/a~1b/c~0d"},
+	}
+
+	for _, tc := range tests {
+		actual := jsonPointer(tc.location)
+		if actual != tc.expected {
+			t.Errorf("jsonPointer(%v): expected %v, actual %v", tc.location, tc.expected, actual)
+		}
+	}
+}
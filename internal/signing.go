@@ -0,0 +1,143 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// SigningAWSSigV4 signs requests with AWS Signature Version 4
+	SigningAWSSigV4 = "awsSigV4"
+
+	// SigningHTTPSignature signs requests with HTTP message signatures (RFC 9421)
+	SigningHTTPSignature = "httpSignature"
+)
+
+// Signing configures how a test request is signed. Signing happens after all
+// headers are resolved, right before the request is sent.
+type Signing struct {
+	Type string `yaml:"type"`
+
+	// awsSigV4
+	Region  string `yaml:"region,omitempty"`
+	Service string `yaml:"service,omitempty"`
+
+	// httpSignature
+	Algorithm  string   `yaml:"algorithm,omitempty"`
+	KeyID      string   `yaml:"keyId,omitempty"`
+	Key        string   `yaml:"key,omitempty"`
+	Passphrase string   `yaml:"passphrase,omitempty"`
+	Components []string `yaml:"components,omitempty"`
+	Label      string   `yaml:"label,omitempty"`
+	Expires    string   `yaml:"expires,omitempty"`
+	Digest     string   `yaml:"digest,omitempty"`
+}
+
+// signFunc signs a request with its body
+type signFunc func(req *http.Request, body []byte) error
+
+// validate checks the signing configuration and assigns default values
+func (s *Signing) validate() error {
+	switch s.Type {
+	case SigningAWSSigV4:
+		s.Region = stringValue(s.Region, stringValue(os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION")))
+		if len(s.Region) == 0 {
+			return fmt.Errorf("region is required, or set AWS_REGION")
+		}
+		if len(s.Service) == 0 {
+			return fmt.Errorf("service is required")
+		}
+		return nil
+
+	case SigningHTTPSignature:
+		if !containsFold(httpSigAlgorithms, s.Algorithm) {
+			return fmt.Errorf("unknown algorithm %q. supported: %s", s.Algorithm, strings.Join(httpSigAlgorithms, ", "))
+		}
+		s.Algorithm = strings.ToLower(s.Algorithm)
+		if len(s.Key) == 0 {
+			return fmt.Errorf("key is required")
+		}
+		if len(s.Expires) > 0 {
+			if _, err := time.ParseDuration(s.Expires); err != nil {
+				return fmt.Errorf("invalid expires: %s", err)
+			}
+		}
+		if _, err := contentDigest(stringValue(s.Digest, "sha-256"), nil); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown type %q. supported: %s, %s", s.Type, SigningAWSSigV4, SigningHTTPSignature)
+}
+
+// signer returns the function that signs requests
+func (s *Signing) signer() (signFunc, error) {
+	switch s.Type {
+	case SigningAWSSigV4:
+		credentials, err := awsCredentialsFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return func(req *http.Request, body []byte) error {
+			return signAWSSigV4(req, body, credentials, s.Region, s.Service, time.Now())
+		}, nil
+
+	case SigningHTTPSignature:
+		signer, err := newHTTPSigner(s)
+		if err != nil {
+			return nil, err
+		}
+		return func(req *http.Request, body []byte) error {
+			return signer.sign(req, body, time.Now())
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown signing type %q", s.Type)
+}
+
+// signingTransport signs each request, including retries, before sending it
+type signingTransport struct {
+	sign signFunc
+	next http.RoundTripper
+}
+
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = data
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if err := t.sign(req, body); err != nil {
+		return nil, fmt.Errorf("unable to sign request: %s", err)
+	}
+
+	return t.next.RoundTrip(req)
+}
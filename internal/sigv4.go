@@ -0,0 +1,207 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html
+const (
+	awsSigV4Algorithm  = "AWS4-HMAC-SHA256"
+	awsSigV4TimeFormat = "20060102T150405Z"
+)
+
+// Headers that proxies and clients may change, so they are never signed
+var awsSigV4UnsignedHeaders = []string{"authorization", "user-agent", "x-amzn-trace-id", "expect", "connection"}
+
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// awsCredentialsFromEnv reads credentials from the standard AWS environment variables
+func awsCredentialsFromEnv() (awsCredentials, error) {
+	credentials := awsCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if len(credentials.AccessKeyID) == 0 || len(credentials.SecretAccessKey) == 0 {
+		return credentials, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required for %s signing", SigningAWSSigV4)
+	}
+	return credentials, nil
+}
+
+// signAWSSigV4 adds AWS Signature Version 4 headers to a request
+func signAWSSigV4(req *http.Request, body []byte, credentials awsCredentials, region, service string, t time.Time) error {
+	amzDate := t.UTC().Format(awsSigV4TimeFormat)
+	scope := strings.Join([]string{amzDate[:8], region, service, "aws4_request"}, "/")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if len(credentials.SessionToken) > 0 {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	payloadHash := sha256Hex(body)
+	if service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	canonicalHeaders, signedHeaders := awsCanonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsCanonicalURI(req.URL, service),
+		awsCanonicalQuery(req.URL.RawQuery),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		awsSigV4Algorithm,
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	// Derive the signing key for the date, region and service
+	key := []byte("AWS4" + credentials.SecretAccessKey)
+	for _, part := range []string{amzDate[:8], region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		awsSigV4Algorithm, credentials.AccessKeyID, scope, signedHeaders, signature))
+
+	return nil
+}
+
+// awsCanonicalURI normalizes and encodes the path. Services other than S3 expect
+// each segment to be encoded twice.
+func awsCanonicalURI(u *url.URL, service string) string {
+	p := u.EscapedPath()
+	if len(p) == 0 {
+		return "/"
+	}
+
+	// S3 keys are used as is, encoded once
+	if service == "s3" {
+		segments := strings.Split(p, "/")
+		for i, segment := range segments {
+			if unescaped, err := url.PathUnescape(segment); err == nil {
+				segment = unescaped
+			}
+			segments[i] = awsURIEncode(segment)
+		}
+		return strings.Join(segments, "/")
+	}
+
+	// Remove dot segments and duplicate slashes, keeping a trailing slash
+	normalized := path.Clean(p)
+	if strings.HasSuffix(p, "/") && normalized != "/" {
+		normalized += "/"
+	}
+
+	segments := strings.Split(normalized, "/")
+	for i, segment := range segments {
+		segments[i] = awsURIEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// awsCanonicalQuery encodes query parameters and sorts them by name, then value
+func awsCanonicalQuery(rawQuery string) string {
+	params := []string{}
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if len(pair) == 0 {
+			continue
+		}
+		name, value, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if unescaped, err := url.QueryUnescape(value); err == nil {
+			value = unescaped
+		}
+		params = append(params, awsURIEncode(name)+"="+awsURIEncode(value))
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// awsCanonicalHeaders returns the canonical headers block and the list of signed headers
+func awsCanonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if len(host) == 0 {
+		host = req.URL.Host
+	}
+	headers := map[string][]string{"host": {host}}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if containsFold(awsSigV4UnsignedHeaders, name) {
+			continue
+		}
+		headers[name] = append(headers[name], values...)
+	}
+
+	var canonical strings.Builder
+	names := sortedKeys(headers)
+	for _, name := range names {
+		values := []string{}
+		for _, value := range headers[name] {
+			values = append(values, strings.Join(strings.Fields(value), " "))
+		}
+		fmt.Fprintf(&canonical, "%s:%s\n", name, strings.Join(values, ","))
+	}
+
+	return canonical.String(), strings.Join(names, ";")
+}
+
+// awsURIEncode percent-encodes everything except unreserved characters
+func awsURIEncode(s string) string {
+	var buf strings.Builder
+	for _, b := range []byte(s) {
+		if ('A' <= b && b <= 'Z') || ('a' <= b && b <= 'z') || ('0' <= b && b <= '9') || b == '-' || b == '_' || b == '.' || b == '~' {
+			buf.WriteByte(b)
+		} else {
+			fmt.Fprintf(&buf, "%%%02X", b)
+		}
+	}
+	return buf.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test vectors from the AWS Signature Version 4 test suite
+func TestSignAWSSigV4(t *testing.T) {
+	credentials := awsCredentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	signingTime := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	var tests = []struct {
+		name      string
+		method    string
+		url       string
+		signature string
+	}{
+		{"get-vanilla", "GET", "https://example.amazonaws.com/", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"get-vanilla-query-order-key-case", "GET", "https://example.amazonaws.com/?Param2=value2&Param1=value1", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+		{"post-vanilla", "POST", "https://example.amazonaws.com/", "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b"},
+	}
+
+	for _, tc := range tests {
+		req, err := http.NewRequest(tc.method, tc.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := signAWSSigV4(req, nil, credentials, "us-east-1", "service", signingTime); err != nil {
+			t.Errorf("signAWSSigV4(%s): unexpected error %v", tc.name, err)
+			continue
+		}
+
+		expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=" + tc.signature
+		if actual := req.Header.Get("Authorization"); actual != expected {
+			t.Errorf("signAWSSigV4(%s): expected %s, actual %s", tc.name, expected, actual)
+		}
+		if actual := req.Header.Get("X-Amz-Date"); actual != "20150830T123600Z" {
+			t.Errorf("signAWSSigV4(%s): unexpected X-Amz-Date %s", tc.name, actual)
+		}
+	}
+}
+
+func TestSignAWSSigV4SessionTokenAndPayload(t *testing.T) {
+	credentials := awsCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "token"}
+
+	req, err := http.NewRequest("PUT", "https://bucket.s3.amazonaws.com/my key.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := signAWSSigV4(req, []byte("hello"), credentials, "us-east-1", "s3", time.Now()); err != nil {
+		t.Fatalf("signAWSSigV4: unexpected error %v", err)
+	}
+
+	if actual := req.Header.Get("X-Amz-Security-Token"); actual != "token" {
+		t.Errorf("signAWSSigV4: expected session token header, actual %s", actual)
+	}
+	if actual := req.Header.Get("X-Amz-Content-Sha256"); actual != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("signAWSSigV4: unexpected payload hash %s", actual)
+	}
+	if actual := req.Header.Get("Authorization"); !strings.Contains(actual, "SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token,") {
+		t.Errorf("signAWSSigV4: unexpected signed headers %s", actual)
+	}
+}
+
+func TestAWSCanonicalURI(t *testing.T) {
+	var tests = []struct {
+		url      string
+		service  string
+		expected string
+	}{
+		{"https://example.com", "service", "/"},
+		{"https://example.com/a/./b/../c//d/", "execute-api", "/a/c/d/"},
+		{"https://example.com/example space/", "execute-api", "/example%2520space/"},
+		{"https://example.com/my key!.txt", "s3", "/my%20key%21.txt"},
+		{"https://example.com/a/../b", "s3", "/a/../b"},
+	}
+
+	for _, tc := range tests {
+		req, err := http.NewRequest("GET", tc.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := awsCanonicalURI(req.URL, tc.service); actual != tc.expected {
+			t.Errorf("awsCanonicalURI(%s, %s): expected %s, actual %s", tc.url, tc.service, tc.expected, actual)
+		}
+	}
+}
+
+func TestAWSCanonicalQuery(t *testing.T) {
+	var tests = []struct {
+		query    string
+		expected string
+	}{
+		{"", ""},
+		{"b=2&a=1&a=0", "a=0&a=1&b=2"},
+		{"q=a+b&x=%2F&flag", "flag=&q=a%20b&x=%2F"},
+	}
+
+	for _, tc := range tests {
+		if actual := awsCanonicalQuery(tc.query); actual != tc.expected {
+			t.Errorf("awsCanonicalQuery(%s): expected %s, actual %s", tc.query, tc.expected, actual)
+		}
+	}
+}
+
+func TestSigningValidate(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "eu-west-1")
+
+	var tests = []struct {
+		signing  Signing
+		region   string
+		hasError bool
+	}{
+		{Signing{Type: SigningAWSSigV4, Region: "us-east-1", Service: "execute-api"}, "us-east-1", false},
+		{Signing{Type: SigningAWSSigV4, Service: "s3"}, "eu-west-1", false},
+		{Signing{Type: SigningAWSSigV4, Region: "us-east-1"}, "us-east-1", true},
+		{Signing{Type: "unknown"}, "", true},
+	}
+
+	for _, tc := range tests {
+		err := tc.signing.validate()
+		if (err != nil) != tc.hasError || tc.signing.Region != tc.region {
+			t.Errorf("validate(%+v): unexpected result %v", tc.signing, err)
+		}
+	}
+}
+
+func TestSendHTTPRequestSigned(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(r.Header.Get("Authorization") + "|" + string(body)))
+	}))
+	defer server.Close()
+
+	signed := 0
+	_, body, err := SendHTTPRequest(&HTTPRequestConfig{
+		Method: "POST",
+		URL:    server.URL,
+		Body:   strings.NewReader("payload"),
+		Sign: func(req *http.Request, body []byte) error {
+			signed++
+			req.Header.Set("Authorization", "signed "+string(body))
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("SendHTTPRequest: unexpected error %v", err)
+	}
+	if string(body) != "signed payload|payload" || signed != 1 {
+		t.Errorf("SendHTTPRequest: unexpected signed request %q, signed %d times", body, signed)
+	}
+}
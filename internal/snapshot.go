@@ -0,0 +1,198 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// snapshotDirectory is the directory, beside the test file, that holds snapshot files
+const snapshotDirectory = "__snapshots__"
+
+// scrubbedValue replaces text matched by Snapshot.Scrub patterns
+const scrubbedValue = "[SCRUBBED]"
+
+// Snapshot compares a response body against a golden file
+type Snapshot struct {
+	Name   string   `yaml:"name"`
+	JSON   bool     `yaml:"json,omitempty"`
+	Ignore []string `yaml:"ignore,omitempty"`
+	Scrub  []string `yaml:"scrub,omitempty"`
+}
+
+// UnmarshalYAML allows a snapshot to be given by name only
+func (s *Snapshot) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		s.Name = name
+		return nil
+	}
+
+	type plain Snapshot
+	return unmarshal((*plain)(s))
+}
+
+// MarshalYAML writes a snapshot without normalization options by name only
+func (s Snapshot) MarshalYAML() (interface{}, error) {
+	if !s.JSON && len(s.Ignore) == 0 && len(s.Scrub) == 0 {
+		return s.Name, nil
+	}
+
+	type plain Snapshot
+	return plain(s), nil
+}
+
+// snapshotPath returns the location of a snapshot file for a test file directory
+func snapshotPath(directory, name string) (string, error) {
+	if len(name) == 0 {
+		return "", fmt.Errorf("snapshot name is required")
+	}
+	if name != filepath.Base(name) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid snapshot name %q", name)
+	}
+	return filepath.Join(directory, snapshotDirectory, name+".snap"), nil
+}
+
+// normalize applies the snapshot's normalization options to a response body
+func (s *Snapshot) normalize(body []byte) (string, error) {
+	normalized := string(body)
+
+	// JSON canonicalization; ignoring paths implies JSON
+	if s.JSON || len(s.Ignore) > 0 {
+		var value interface{}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			return "", fmt.Errorf("response body is not valid JSON: %s", err)
+		}
+
+		for _, path := range s.Ignore {
+			value = deleteJSONPath(value, strings.Split(path, "."))
+		}
+
+		canonical, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		normalized = string(canonical) + "\n"
+	}
+
+	// Scrub volatile values
+	for _, pattern := range s.Scrub {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return "", fmt.Errorf("invalid snapshot scrub pattern `%s`: %s", pattern, err.Error())
+		}
+		normalized = re.ReplaceAllString(normalized, scrubbedValue)
+	}
+
+	return normalized, nil
+}
+
+// deleteJSONPath removes the element at a dot-delimited path from a decoded JSON value.
+// Array elements are addressed by index and * matches every key or element.
+func deleteJSONPath(value interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return value
+	}
+	key, rest := path[0], path[1:]
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k := range v {
+			if key != "*" && key != k {
+				continue
+			}
+			if len(rest) == 0 {
+				delete(v, k)
+			} else {
+				v[k] = deleteJSONPath(v[k], rest)
+			}
+		}
+		return v
+	case []interface{}:
+		if key == "*" {
+			if len(rest) == 0 {
+				return []interface{}{}
+			}
+			for i := range v {
+				v[i] = deleteJSONPath(v[i], rest)
+			}
+			return v
+		}
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(v) {
+			return v
+		}
+		if len(rest) == 0 {
+			return append(v[:i], v[i+1:]...)
+		}
+		v[i] = deleteJSONPath(v[i], rest)
+		return v
+	}
+
+	return value
+}
+
+// validateSnapshot compares a response body with a test's snapshot file, writing the file instead in update mode
+func validateSnapshot(test *Test, body []byte, config *Config) []error {
+	snapshot := test.Response.Body.Snapshot
+
+	path, err := snapshotPath(test.Directory, snapshot.Name)
+	if err != nil {
+		return []error{err}
+	}
+
+	actual, err := snapshot.normalize(body)
+	if err != nil {
+		return []error{err}
+	}
+
+	if config.UpdateSnapshots {
+		if err := writeSnapshot(path, actual); err != nil {
+			return []error{fmt.Errorf("unable to write snapshot %s: %s", path, err)}
+		}
+		return nil
+	}
+
+	expected, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []error{fmt.Errorf("snapshot %q not found at %s. run with TEST_UPDATE_SNAPSHOTS=true to create it", snapshot.Name, path)}
+	}
+	if err != nil {
+		return []error{fmt.Errorf("unable to read snapshot %s: %s", path, err)}
+	}
+
+	if diff := redactedDiff(config.Redactor, string(expected), actual, "snapshot/"+snapshot.Name, "response body"); len(diff) > 0 {
+		return []error{fmt.Errorf("response body does not match snapshot %q\n%s", snapshot.Name, diff)}
+	}
+
+	return nil
+}
+
+func writeSnapshot(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
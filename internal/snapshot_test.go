@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestSnapshotUnmarshalYAML(t *testing.T) {
+	var tests = []struct {
+		yaml     string
+		expected Snapshot
+	}{
+		{
+			`snapshot: home`,
+			Snapshot{Name: "home"},
+		},
+		{
+			"snapshot:\n  name: home\n  json: true\n  ignore: [a.b]\n  scrub: ['\\d+']",
+			Snapshot{Name: "home", JSON: true, Ignore: []string{"a.b"}, Scrub: []string{`\d+`}},
+		},
+	}
+
+	for _, tc := range tests {
+		var actual struct {
+			Snapshot Snapshot `yaml:"snapshot"`
+		}
+		if err := yaml.Unmarshal([]byte(tc.yaml), &actual); err != nil {
+			t.Errorf("yaml.Unmarshal(%s): unexpected error %v", tc.yaml, err)
+			continue
+		}
+		if actual.Snapshot.Name != tc.expected.Name || actual.Snapshot.JSON != tc.expected.JSON ||
+			strings.Join(actual.Snapshot.Ignore, ",") != strings.Join(tc.expected.Ignore, ",") ||
+			strings.Join(actual.Snapshot.Scrub, ",") != strings.Join(tc.expected.Scrub, ",") {
+			t.Errorf("yaml.Unmarshal(%s): expected %+v, actual %+v", tc.yaml, tc.expected, actual.Snapshot)
+		}
+	}
+}
+
+func TestSnapshotNormalize(t *testing.T) {
+	var tests = []struct {
+		snapshot Snapshot
+		body     string
+		expected string
+	}{
+		{
+			Snapshot{},
+			"plain body 123",
+			"plain body 123",
+		},
+		{
+			Snapshot{Scrub: []string{`\d+`}},
+			"plain body 123",
+			"plain body [SCRUBBED]",
+		},
+		{
+			Snapshot{JSON: true},
+			`{"b":1,"a":[1,2]}`,
+			"{\n  \"a\": [\n    1,\n    2\n  ],\n  \"b\": 1\n}\n",
+		},
+		{
+			Snapshot{Ignore: []string{"meta.requestId", "items.*.updated", "list.0"}},
+			`{"meta":{"requestId":"abc","page":1},"items":[{"id":1,"updated":"x"},{"id":2,"updated":"y"}],"list":[1,2]}`,
+			"{\n  \"items\": [\n    {\n      \"id\": 1\n    },\n    {\n      \"id\": 2\n    }\n  ],\n  \"list\": [\n    2\n  ],\n  \"meta\": {\n    \"page\": 1\n  }\n}\n",
+		},
+		{
+			Snapshot{JSON: true},
+			`{"big":12345678901234567890}`,
+			"{\n  \"big\": 12345678901234567890\n}\n",
+		},
+	}
+
+	for _, tc := range tests {
+		actual, err := tc.snapshot.normalize([]byte(tc.body))
+		if err != nil {
+			t.Errorf("normalize(%s): unexpected error %v", tc.body, err)
+			continue
+		}
+		if actual != tc.expected {
+			t.Errorf("normalize(%s): expected %q, actual %q", tc.body, tc.expected, actual)
+		}
+	}
+
+	if _, err := (&Snapshot{JSON: true}).normalize([]byte("not json")); err == nil {
+		t.Errorf("normalize(not json): expected error")
+	}
+}
+
+func TestValidateSnapshot(t *testing.T) {
+	test := &Test{Directory: t.TempDir()}
+	test.Response.Body.Snapshot = &Snapshot{Name: "body"}
+
+	if errs := validateSnapshot(test, []byte("a\nb\n"), &Config{}); len(errs) != 1 || !strings.Contains(errs[0].Error(), "not found") {
+		t.Errorf("validateSnapshot: expected missing snapshot error, got %v", errs)
+	}
+
+	if errs := validateSnapshot(test, []byte("a\nb\n"), &Config{UpdateSnapshots: true}); len(errs) != 0 {
+		t.Errorf("validateSnapshot: unexpected errors in update mode %v", errs)
+	}
+
+	if errs := validateSnapshot(test, []byte("a\nb\n"), &Config{}); len(errs) != 0 {
+		t.Errorf("validateSnapshot: unexpected errors %v", errs)
+	}
+
+	errs := validateSnapshot(test, []byte("a\nc\n"), &Config{})
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "-b\n+c\n") {
+		t.Errorf("validateSnapshot: expected diff error, got %v", errs)
+	}
+
+	test.Response.Body.Snapshot.Name = "../body"
+	if errs := validateSnapshot(test, []byte(""), &Config{UpdateSnapshots: true}); len(errs) != 1 {
+		t.Errorf("validateSnapshot: expected invalid name error, got %v", errs)
+	}
+}
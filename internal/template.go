@@ -0,0 +1,469 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/nytimes/httptest/functions"
+	"go.uber.org/zap"
+)
+
+// Template expressions are evaluated at run time in request and assertion
+// values. An expression is a pipeline of commands, each a function of the
+// registry with its arguments, or a single value:
+//
+//	{{ token }}                      value of a variable
+//	{{ uuid() }}                     function call with comma-separated arguments
+//	{{ concat 'order-' id }}         function call with space-separated arguments
+//	{{ now | timeFormat 'RFC3339' }} the value of a command is the last argument of the next one
+//	{{ base64Encode (concat a b) }}  parenthesized pipeline as an argument
+
+type templateTokenType int
+
+const (
+	tokenName templateTokenType = iota
+	tokenCall                   // name directly followed by (
+	tokenString
+	tokenLeftParen
+	tokenRightParen
+	tokenComma
+	tokenPipe
+	tokenEOF
+)
+
+type templateToken struct {
+	typ   templateTokenType
+	value string
+}
+
+// lexTemplate splits an expression into tokens
+func lexTemplate(expr string) ([]templateToken, error) {
+	tokens := []templateToken{}
+	runes := []rune(expr)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, templateToken{tokenLeftParen, "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, templateToken{tokenRightParen, ")"})
+			i++
+		case r == ',':
+			tokens = append(tokens, templateToken{tokenComma, ","})
+			i++
+		case r == '|':
+			tokens = append(tokens, templateToken{tokenPipe, "|"})
+			i++
+		case r == '\'' || r == '"':
+			// Quoted string, where a backslash escapes the next character
+			var value strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				value.WriteRune(runes[j])
+			}
+			if j == len(runes) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, templateToken{tokenString, value.String()})
+			i = j + 1
+		case unicode.IsDigit(r) || r == '-' || r == '+':
+			// Numbers are strings, as all values are
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, templateToken{tokenString, string(runes[i:j])})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || strings.ContainsRune("_.-", runes[j])) {
+				j++
+			}
+			typ := tokenName
+			if j < len(runes) && runes[j] == '(' {
+				typ = tokenCall
+			}
+			tokens = append(tokens, templateToken{typ, string(runes[i:j])})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q", r)
+		}
+	}
+
+	return append(tokens, templateToken{typ: tokenEOF}), nil
+}
+
+// templateOperand is a string, a name, or a parenthesized pipeline
+type templateOperand struct {
+	value    string
+	name     bool
+	pipeline templatePipeline
+}
+
+// templateCommand is a function with its arguments, or a single operand
+type templateCommand struct {
+	operands []templateOperand
+
+	// call is set for the name(args) form, which is always a function call
+	call bool
+}
+
+type templatePipeline []templateCommand
+
+type templateParser struct {
+	tokens []templateToken
+	pos    int
+}
+
+// parseTemplate parses an expression
+func parseTemplate(expr string) (templatePipeline, error) {
+	tokens, err := lexTemplate(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &templateParser{tokens: tokens}
+	pipeline, err := p.pipeline()
+	if err != nil {
+		return nil, err
+	}
+	if next := p.next(); next.typ != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q", next.value)
+	}
+	return pipeline, nil
+}
+
+func (p *templateParser) peek() templateToken {
+	return p.tokens[p.pos]
+}
+
+func (p *templateParser) next() templateToken {
+	token := p.tokens[p.pos]
+	if token.typ != tokenEOF {
+		p.pos++
+	}
+	return token
+}
+
+func (p *templateParser) pipeline() (templatePipeline, error) {
+	pipeline := templatePipeline{}
+	for {
+		command, err := p.command()
+		if err != nil {
+			return nil, err
+		}
+		pipeline = append(pipeline, command)
+
+		if p.peek().typ != tokenPipe {
+			return pipeline, nil
+		}
+		p.next()
+	}
+}
+
+func (p *templateParser) command() (templateCommand, error) {
+	command := templateCommand{}
+
+	// name(arg, arg)
+	if p.peek().typ == tokenCall {
+		command.call = true
+		command.operands = append(command.operands, templateOperand{value: p.next().value, name: true})
+		p.next()
+
+		for p.peek().typ != tokenRightParen {
+			arg, err := p.pipeline()
+			if err != nil {
+				return command, err
+			}
+			command.operands = append(command.operands, templateOperand{pipeline: arg})
+
+			if p.peek().typ != tokenComma {
+				break
+			}
+			p.next()
+		}
+		if token := p.next(); token.typ != tokenRightParen {
+			return command, fmt.Errorf("expected ) after arguments of %s", command.operands[0].value)
+		}
+		return command, nil
+	}
+
+	// name arg arg
+	for {
+		switch token := p.peek(); token.typ {
+		case tokenName:
+			p.next()
+			command.operands = append(command.operands, templateOperand{value: token.value, name: true})
+		case tokenString:
+			p.next()
+			command.operands = append(command.operands, templateOperand{value: token.value})
+		case tokenCall:
+			// A call as an argument takes no further arguments
+			call, err := p.command()
+			if err != nil {
+				return command, err
+			}
+			command.operands = append(command.operands, templateOperand{pipeline: templatePipeline{call}})
+		case tokenLeftParen:
+			p.next()
+			pipeline, err := p.pipeline()
+			if err != nil {
+				return command, err
+			}
+			if token := p.next(); token.typ != tokenRightParen {
+				return command, fmt.Errorf("expected )")
+			}
+			command.operands = append(command.operands, templateOperand{pipeline: pipeline})
+		default:
+			if len(command.operands) == 0 {
+				if token.typ == tokenEOF {
+					return command, fmt.Errorf("missing expression")
+				}
+				return command, fmt.Errorf("unexpected %q", token.value)
+			}
+			return command, nil
+		}
+	}
+}
+
+// templateScope evaluates expressions with variables and the functions of funcMap
+type templateScope struct {
+	request *functions.RequestContext
+	values  map[string]string
+}
+
+func (s *templateScope) pipeline(pipeline templatePipeline) (string, error) {
+	var piped *string
+	for _, command := range pipeline {
+		value, err := s.command(command, piped)
+		if err != nil {
+			return "", err
+		}
+		piped = &value
+	}
+	return *piped, nil
+}
+
+func (s *templateScope) command(command templateCommand, piped *string) (string, error) {
+	first := command.operands[0]
+
+	// A single value, unless it names a function
+	if !command.call && len(command.operands) == 1 && piped == nil {
+		if !first.name {
+			return s.operand(first)
+		}
+		if value, ok := s.values[first.value]; ok {
+			return value, nil
+		}
+	}
+
+	fn, ok := lookupFunction(first.value)
+	if !first.name || !ok {
+		if first.name && len(command.operands) == 1 && piped == nil {
+			return "", fmt.Errorf("unknown variable or function %s", first.value)
+		}
+		return "", fmt.Errorf("%s is not a function", first.value)
+	}
+
+	args := []string{}
+	for _, operand := range command.operands[1:] {
+		value, err := s.operand(operand)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, value)
+	}
+	if piped != nil {
+		args = append(args, *piped)
+	}
+
+	value, err := fn(s.request, args)
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", first.value, err)
+	}
+	return value, nil
+}
+
+func (s *templateScope) operand(operand templateOperand) (string, error) {
+	switch {
+	case operand.pipeline != nil:
+		return s.pipeline(operand.pipeline)
+	case operand.name:
+		value, ok := s.values[operand.value]
+		if !ok {
+			return "", fmt.Errorf("unknown variable %s", operand.value)
+		}
+		return value, nil
+	}
+	return operand.value, nil
+}
+
+// renderTemplate evaluates the expressions of a string. Handlebars-style
+// blocks, partials and comments such as {{#each items}}, and single names
+// that are neither variables nor functions, such as {{name}}, are left
+// unchanged. Expressions that don't parse or call unknown functions fail.
+// Functions send HTTP requests with transport.
+func renderTemplate(s string, values map[string]string, transport http.RoundTripper) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+
+	// Functions see the variables in place of headers
+	headers := map[string]string{}
+	for name, value := range values {
+		headers[name] = value
+	}
+	scope := &templateScope{request: &functions.RequestContext{Headers: headers, Transport: transport}, values: values}
+
+	var buf strings.Builder
+	for {
+		start := strings.Index(s, "{{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(s[start:], "}}")
+		if end < 0 {
+			break
+		}
+		end += start
+
+		expr := s[start+2 : end]
+		buf.WriteString(s[:start])
+		s = s[end+2:]
+
+		// Leave the blocks of handlebars templates as they are
+		if trimmed := strings.TrimSpace(expr); len(trimmed) > 0 && strings.ContainsRune(handlebarsSigils, rune(trimmed[0])) {
+			buf.WriteString("{{" + expr + "}}")
+			continue
+		}
+
+		pipeline, err := parseTemplate(expr)
+		if err != nil {
+			return "", fmt.Errorf("unable to parse {{%s}}: %s", expr, err)
+		}
+
+		// A single unknown name may be the placeholder of another template
+		// language, or a misspelled variable
+		if name, ok := scope.unknownName(pipeline); ok {
+			zap.L().Warn("leaving template expression of unknown variable or function unchanged",
+				zap.String("expression", "{{"+expr+"}}"),
+				zap.String("name", name),
+			)
+			buf.WriteString("{{" + expr + "}}")
+			continue
+		}
+
+		value, err := scope.pipeline(pipeline)
+		if err != nil {
+			return "", fmt.Errorf("unable to evaluate {{%s}}: %s", expr, err)
+		}
+		buf.WriteString(value)
+	}
+	buf.WriteString(s)
+
+	return buf.String(), nil
+}
+
+// First characters of handlebars blocks, partials and comments
+const handlebarsSigils = "#/>!^"
+
+// unknownName returns the name of a pipeline that is a single name of neither
+// a variable nor a function
+func (s *templateScope) unknownName(pipeline templatePipeline) (string, bool) {
+	if len(pipeline) != 1 {
+		return "", false
+	}
+	command := pipeline[0]
+	first := command.operands[0]
+	if command.call || len(command.operands) != 1 || !first.name {
+		return "", false
+	}
+	if _, ok := s.values[first.value]; ok {
+		return "", false
+	}
+	if _, ok := lookupFunction(first.value); ok {
+		return "", false
+	}
+	return first.value, true
+}
+
+// applyTemplates evaluates expressions in the request and assertion values of a
+// test. Variables that aren't resolved yet are resolved with transport.
+func applyTemplates(test *Test, global *variableScope, transport http.RoundTripper) error {
+	values := map[string]string{}
+	if scope := test.variables; scope != nil {
+		resolved, err := scope.resolve(global, transport)
+		if err != nil {
+			return err
+		}
+		values = resolved
+	} else if global != nil {
+		resolved, err := global.resolve(nil, transport)
+		if err != nil {
+			return err
+		}
+		values = resolved
+	}
+
+	var err error
+	render := func(s *string) {
+		if err == nil {
+			*s, err = renderTemplate(*s, values, test.transport)
+		}
+	}
+	renderMap := func(m map[string]string) {
+		for name, value := range m {
+			render(&value)
+			m[name] = value
+		}
+	}
+
+	render(&test.Request.Path)
+	render(&test.Request.Body)
+	renderMap(test.Request.Headers)
+	renderMap(test.Request.Query)
+	for i := range test.Request.DynamicHeaders {
+		args := test.Request.DynamicHeaders[i].Args
+		for j := range args {
+			render(&args[j])
+		}
+	}
+
+	renderMap(test.Response.Headers.Patterns)
+	renderMap(test.Response.Headers.NotMatching)
+	renderMap(test.Response.Headers.IfPresentNotMatching)
+	for i := range test.Response.Body.Patterns {
+		render(&test.Response.Body.Patterns[i])
+	}
+	for i := range test.Response.Assertions {
+		args := test.Response.Assertions[i].Args
+		for j := range args {
+			render(&args[j])
+		}
+	}
+
+	return err
+}
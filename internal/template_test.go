@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	values := map[string]string{"token": "abc", "user.id": "42", "x-prefix": "order-"}
+
+	var tests = []struct {
+		s        string
+		expected string
+	}{
+		{"Bearer {{token}}", "Bearer abc"},
+		{"/users/{{ user.id }}/orders?token={{token}}", "/users/42/orders?token=abc"},
+		{"{{unknown}} {{ token }}", "{{unknown}} abc"},
+		{`{"a": {"b": 1}}`, `{"a": {"b": 1}}`},
+		{"no expressions", "no expressions"},
+		{"{{ concat 'a' \"b\" 3 }}", "ab3"},
+		{"{{ concat(x-prefix, user.id) }}", "order-42"},
+		{"{{ concat x-prefix (concat user.id '-' token) }}", "order-42-abc"},
+		{"{{ token | concat 'x' }}", "xabc"},
+		{"{{ 'a' | concat 'b' | concat 'c' }}", "cba"},
+		{"{{ concat('it\\'s', ' ', concat 'a' 'b') }}", "it's ab"},
+
+		// Left unchanged
+		{"{{ token", "{{ token"},
+		{"Hello {{ name }}, {{token}}", "Hello {{ name }}, abc"},
+		{"{{#each items}}{{this}}{{/each}}", "{{#each items}}{{this}}{{/each}}"},
+		{"{{> header}}{{! comment }}{{^empty}}", "{{> header}}{{! comment }}{{^empty}}"},
+	}
+
+	for _, tc := range tests {
+		actual, err := renderTemplate(tc.s, values, nil)
+		if err != nil {
+			t.Errorf("renderTemplate(%s): unexpected error %v", tc.s, err)
+			continue
+		}
+		if actual != tc.expected {
+			t.Errorf("renderTemplate(%s): expected %s, actual %s", tc.s, tc.expected, actual)
+		}
+	}
+}
+
+func TestRenderTemplateErrors(t *testing.T) {
+	values := map[string]string{"token": "abc"}
+
+	var tests = []string{
+		"{{ base64Decode('not base64!') }}",
+		"{{ randomInt('a', 'b') }}",
+		"{{ token | base64Decode }}",
+
+		// Unparsable expressions and unknown functions
+		"{{ }}",
+		"{{ concat 'a }}",
+		"{{ concat(token }}",
+		"{{ concat missing }}",
+		"{{ token 'a' }}",
+		"{{ 'a' | token }}",
+		"{{ uuuid() }}",
+		"{{ concat ) }}",
+		"{{ concat # }}",
+		"{{ .Name | printf \"%s\" }}",
+	}
+
+	for _, s := range tests {
+		if actual, err := renderTemplate(s, values, nil); err == nil {
+			t.Errorf("renderTemplate(%s): expected error, actual %s", s, actual)
+		}
+	}
+}
+
+func TestApplyTemplatesLiteralBraces(t *testing.T) {
+	test := &Test{}
+	test.Request.Path = "/render"
+	test.Request.Body = `{"template": "Hello {{ name }}!", "partial": "{{> header}}"}`
+	test.Response.Body.Patterns = []string{`Hello {{ name }}`}
+
+	if err := applyTemplates(test, nil, nil); err != nil {
+		t.Fatalf("applyTemplates: unexpected error %v", err)
+	}
+	if test.Request.Body != `{"template": "Hello {{ name }}!", "partial": "{{> header}}"}` {
+		t.Errorf("applyTemplates: expected unchanged body, actual %s", test.Request.Body)
+	}
+	if test.Response.Body.Patterns[0] != `Hello {{ name }}` {
+		t.Errorf("applyTemplates: expected unchanged pattern, actual %s", test.Response.Body.Patterns[0])
+	}
+}
+
+func TestRenderTemplateUnknownName(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	actual, err := renderTemplate("Bearer {{ tokn }}", map[string]string{"token": "abc"}, nil)
+	if err != nil || actual != "Bearer {{ tokn }}" {
+		t.Errorf("renderTemplate: expected unchanged expression, actual %s %v", actual, err)
+	}
+	entries := logs.All()
+	if len(entries) != 1 || entries[0].ContextMap()["name"] != "tokn" {
+		t.Errorf("renderTemplate: expected warning for tokn, actual %v", entries)
+	}
+}
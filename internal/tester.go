@@ -19,26 +19,46 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	"go.uber.org/zap"
 )
 
 // TestResult stores results of a single test
 type TestResult struct {
-	Retries int
-	Skipped bool
-	Errors  []error
+	Retries  int
+	Skipped  bool
+	Errors   []error
+	Curl     string
+	Duration time.Duration
 }
 
-// RunTest runs a single test
-func RunTest(test *Test, defaultHost string, maxRetries int) *TestResult {
+// RunTest runs a single test. Requests are cancelled when ctx is done.
+// The test itself is not changed, so it can be run again.
+func RunTest(ctx context.Context, test *Test, config *Config) *TestResult {
+	original := test
+	test = test.clone()
 	result := &TestResult{}
 
-	// Validate test and assign default values
-	if err := preProcessTest(test, defaultHost); err != nil {
+	maxRetries := 0
+	if config.EnableRetries {
+		maxRetries = config.RetryCount
+	}
+
+	// Record requests to the HAR file, one page per test, including the
+	// requests sent by dynamic header functions
+	var pageref string
+	if config.har != nil {
+		pageref = config.har.page(test.Description)
+		test.transport = config.har.transport(http.DefaultTransport, pageref)
+	}
+
+	// Evaluate template expressions, validate test and assign default values
+	if err := prepareTest(test, config, config.Host); err != nil {
 		result.Errors = append(result.Errors, err)
 		return result
 	}
@@ -55,14 +75,14 @@ func RunTest(test *Test, defaultHost string, maxRetries int) *TestResult {
 		return result
 	}
 
-	url := test.Request.Scheme + "://" + test.Request.Host + test.Request.Path
-
-	var body io.Reader
-	if len(test.Request.Body) > 0 {
-		body = strings.NewReader(test.Request.Body)
+	reqConfig, err := newRequestConfig(ctx, test, config, pageref)
+	if err != nil {
+		result.Errors = append(result.Errors, err)
+		return result
 	}
 
-	retryCallback := func(ctx context.Context, resp *http.Response, inErr error) (bool, error) {
+	reqConfig.MaxRetries = maxRetries
+	reqConfig.RetryCallback = func(ctx context.Context, resp *http.Response, inErr error) (bool, error) {
 		if inErr != nil {
 			// retry is there is an error with the request
 			return true, nil
@@ -78,19 +98,13 @@ func RunTest(test *Test, defaultHost string, maxRetries int) *TestResult {
 		return false, nil
 	}
 
-	reqConfig := &HTTPRequestConfig{
-		Method:               test.Request.Method,
-		URL:                  url,
-		Headers:              test.Request.Headers,
-		Body:                 body,
-		TimeoutSeconds:       60,
-		SkipCertVerification: test.SkipCertVerification,
-		RetryCallback:        retryCallback,
-		MaxRetries:           maxRetries,
-	}
-
 	zap.L().Info("sending request",
-		zap.Any("request", reqConfig),
+		zap.Any("request", loggedRequestConfig(reqConfig, config.Redactor)),
+	)
+
+	result.Curl = curlCommand(test, config)
+	zap.L().Info("equivalent curl command",
+		zap.String("curl", result.Curl),
 	)
 
 	for i := 0; i <= maxRetries; i++ {
@@ -104,22 +118,107 @@ func RunTest(test *Test, defaultHost string, maxRetries int) *TestResult {
 		}
 
 		zap.L().Info("got response",
-			zap.ByteString("body", respBody),
+			zap.ByteString("body", config.Redactor.Body(resp.Header.Get("Content-Type"), respBody)),
 			zap.String("status", resp.Status),
-			zap.Any("headers", resp.Header),
+			zap.Any("headers", config.Redactor.HTTPHeader(resp.Header)),
 		)
 
 		// Append response validation errors
-		result.Errors = append(result.Errors, validateResponse(test, resp, respBody)...)
+		result.Errors = append(result.Errors, validateResponse(test, resp, respBody, config)...)
+
+		// Append OpenAPI contract validation errors
+		if config.contract != nil {
+			result.Errors = append(result.Errors, config.contract.validate(test, reqConfig.URL, resp, respBody)...)
+		}
+
+		if len(result.Errors) > 0 {
+			continue
+		}
 
-		if len(result.Errors) == 0 {
-			return result
+		// Compare the response with the response of the baseline host once it passes
+		if comparesWithBaseline(test, config) {
+			baseline := original.clone()
+			baseline.transport = test.transport
+			result.Errors = append(result.Errors, compareWithBaseline(ctx, baseline, resp, respBody, config, pageref)...)
 		}
+		return result
 	}
 
 	return result
 }
 
+// prepareTest evaluates the template expressions of a test, validates it and
+// assigns default values, with host as the default host
+func prepareTest(test *Test, config *Config, host string) error {
+	if err := applyTemplates(test, config.variables, config.functionsTransport()); err != nil {
+		return err
+	}
+	return preProcessTest(test, host)
+}
+
+// newRequestConfig returns the configuration of the request of a prepared
+// test, without retries. The request is recorded on the HAR page pageref.
+func newRequestConfig(ctx context.Context, test *Test, config *Config, pageref string) (*HTTPRequestConfig, error) {
+	// Authorize the request with a token shared by all tests with the same credentials
+	if test.Request.Auth != nil {
+		if config.tokens == nil {
+			return nil, fmt.Errorf("unable to get oauth2 token: config is not applied")
+		}
+		authorization, err := config.tokens.authorization(ctx, test.Request.Auth, config)
+		if err != nil {
+			return nil, err
+		}
+		test.Request.Headers["authorization"] = authorization
+	}
+
+	var body io.Reader
+	if len(test.Request.Body) > 0 {
+		body = strings.NewReader(test.Request.Body)
+	}
+
+	reqConfig := &HTTPRequestConfig{
+		Context:              ctx,
+		Method:               test.Request.Method,
+		URL:                  test.Request.Scheme + "://" + test.Request.Host + test.Request.Path,
+		Headers:              test.Request.Headers,
+		Body:                 body,
+		TimeoutSeconds:       60,
+		SkipCertVerification: test.SkipCertVerification,
+		Proxy:                stringValue(test.Request.Proxy, config.Proxy),
+		ProxyUsername:        config.ProxyUsername,
+		ProxyPassword:        config.ProxyPassword,
+	}
+
+	// Sign the request once it is ready to send
+	if test.Request.Signing != nil {
+		sign, err := test.Request.Signing.signer()
+		if err != nil {
+			return nil, err
+		}
+		reqConfig.Sign = sign
+	}
+
+	if config.har != nil {
+		reqConfig.WrapTransport = func(transport http.RoundTripper) http.RoundTripper {
+			return config.har.transport(transport, pageref)
+		}
+	}
+
+	return reqConfig, nil
+}
+
+// loggedRequestConfig returns a copy of a request configuration with its
+// sensitive values redacted, for logging
+func loggedRequestConfig(reqConfig *HTTPRequestConfig, redactor *Redactor) HTTPRequestConfig {
+	logged := *reqConfig
+	logged.URL = redactor.URL(reqConfig.URL)
+	logged.Headers = redactor.Headers(reqConfig.Headers)
+	logged.Proxy = redactor.URL(reqConfig.Proxy)
+	logged.ProxyPassword = ""
+	logged.BasicAuthPassword = ""
+	return logged
+}
+
 // preProcessTest validates test and assigns default values
 func preProcessTest(test *Test, defaultHost string) error {
 	// Scheme
@@ -152,8 +251,57 @@ func preProcessTest(test *Test, defaultHost string) error {
 		return fmt.Errorf("request.path must start with /")
 	}
 
+	// Query parameters are added to those in the path
+	if len(test.Request.Query) > 0 {
+		query := url.Values{}
+		for name, value := range test.Request.Query {
+			query.Set(name, value)
+		}
+		separator := "?"
+		if strings.Contains(test.Request.Path, "?") {
+			separator = "&"
+		}
+		test.Request.Path += separator + query.Encode()
+		test.Request.Query = nil
+	}
+
+	// Proxy
+	if err := validateProxy(test.Request.Proxy); err != nil {
+		return fmt.Errorf("invalid request.proxy: %s", err)
+	}
+
+	// Signing
+	if test.Request.Signing != nil {
+		if err := test.Request.Signing.validate(); err != nil {
+			return fmt.Errorf("invalid request.signing: %s", err)
+		}
+	}
+
+	// Auth
+	if test.Request.Auth != nil {
+		if err := test.Request.Auth.validate(); err != nil {
+			return fmt.Errorf("invalid request.auth: %s", err)
+		}
+
+		// SigV4 signatures replace the authorization header with the token
+		if test.Request.Signing != nil && test.Request.Signing.Type == SigningAWSSigV4 {
+			return fmt.Errorf("invalid request.auth: can't be combined with signing type %s, which sets the authorization header", SigningAWSSigV4)
+		}
+	}
+
+	// Custom assertions
+	for _, assertion := range test.Response.Assertions {
+		if _, ok := lookupAssertion(assertion.Name); !ok {
+			return fmt.Errorf("unknown assertion %s", assertion.Name)
+		}
+	}
+
 	// Process the dynamic headers
-	if err := ProcessDynamicHeaders(test.Request.DynamicHeaders, test.Request.Headers); err != nil {
+	request, err := newRequestContext(test)
+	if err != nil {
+		return err
+	}
+	if err := ProcessDynamicHeaders(test.Request.DynamicHeaders, request); err != nil {
 		return err
 	}
 
@@ -191,12 +339,13 @@ func validateConditions(test *Test) (bool, error) {
 	return true, nil
 }
 
-func validateResponse(test *Test, response *http.Response, body []byte) []error {
+func validateResponse(test *Test, response *http.Response, body []byte, config *Config) []error {
 	errors := []error{}
 
 	errors = append(errors, validateResponseStatus(test, response)...)
-	errors = append(errors, validateResponseHeaders(test, response)...)
-	errors = append(errors, validateResponseBody(test, response, body)...)
+	errors = append(errors, validateResponseHeaders(test, response, config.Redactor)...)
+	errors = append(errors, validateResponseBody(test, response, body, config)...)
+	errors = append(errors, validateCustomAssertions(test, response, body)...)
 
 	return errors
 }
@@ -213,27 +362,36 @@ func validateResponseStatus(test *Test, response *http.Response) []error {
 	}
 
 	if !matched && len(expected.StatusCodes) > 0 {
-		errors = append(errors, fmt.Errorf("unexpected status code - expected %v, got %d", expected.StatusCodes, response.StatusCode))
+		errors = append(errors, &AssertionError{
+			Message:  fmt.Sprintf("unexpected status code - expected %v, got %d", expected.StatusCodes, response.StatusCode),
+			Expected: fmt.Sprintf("status %v", expected.StatusCodes),
+			Actual:   fmt.Sprintf("status %d", response.StatusCode),
+		})
 	}
 
 	return errors
 }
 
-func validateResponseHeaders(test *Test, response *http.Response) []error {
+func validateResponseHeaders(test *Test, response *http.Response, redactor *Redactor) []error {
 	errors := []error{}
 	expectedResponse := test.Response
 
 	// Patterns (matching assertions)
-	errors = append(errors, validateResponseHeaderPatterns(response, expectedResponse.Headers.Patterns, true)...)
+	errors = append(errors, validateResponseHeaderPatterns(response, expectedResponse.Headers.Patterns, true, redactor)...)
 
 	// NotMatching assertions
-	errors = append(errors, validateResponseHeaderPatterns(response, expectedResponse.Headers.NotMatching, false)...)
+	errors = append(errors, validateResponseHeaderPatterns(response, expectedResponse.Headers.NotMatching, false, redactor)...)
 
 	// NotPresent assertions
 	npAssertions := expectedResponse.Headers.NotPresent
 	for _, header := range npAssertions {
 		if len(response.Header.Get(header)) > 0 {
-			errors = append(errors, fmt.Errorf("found unexpected response header \"%s\"", header))
+			errors = append(errors, &AssertionError{
+				Message:  fmt.Sprintf("found unexpected response header \"%s\"", header),
+				Expected: fmt.Sprintf("%s: (not present)", header),
+				Headers:  response.Header,
+				Header:   header,
+			})
 		}
 	}
 
@@ -244,14 +402,14 @@ func validateResponseHeaders(test *Test, response *http.Response) []error {
 	ipnmHeaders := expectedResponse.Headers.IfPresentNotMatching
 	for header := range ipnmHeaders {
 		if len(response.Header.Get(header)) > 0 {
-			errors = append(errors, validateResponseHeaderPatterns(response, ipnmHeaders, false)...)
+			errors = append(errors, validateResponseHeaderPatterns(response, ipnmHeaders, false, redactor)...)
 		}
 	}
 
 	return errors
 }
 
-func validateResponseHeaderPatterns(response *http.Response, patterns map[string]string, expectedToMatch bool) []error {
+func validateResponseHeaderPatterns(response *http.Response, patterns map[string]string, expectedToMatch bool, redactor *Redactor) []error {
 	errors := []error{}
 
 	// Patterns
@@ -266,35 +424,46 @@ func validateResponseHeaderPatterns(response *http.Response, patterns map[string
 		values, ok := response.Header[http.CanonicalHeaderKey(header)]
 		if !ok {
 			if expectedToMatch {
-				errors = append(errors, fmt.Errorf("response header \"%s\" not found, expected to match pattern \"%s\"", header, pattern))
+				errors = append(errors, headerAssertionError(response, header, fmt.Sprintf("response header \"%s\" not found, expected to match pattern \"%s\"", header, pattern), "matches "+pattern))
 			} else {
-				errors = append(errors, fmt.Errorf("response header \"%s\" not found, expected to be present", header))
+				errors = append(errors, headerAssertionError(response, header, fmt.Sprintf("response header \"%s\" not found, expected to be present", header), "not matching "+pattern))
 			}
 			continue
 		}
 
 		// Try to match pattern from one of the instances
 		matched := false
-		for _, value := range values {
-			value = strings.ToLower(value)
-			if re.MatchString(value) {
+		redactedValues := make([]string, len(values))
+		for i, value := range values {
+			if re.MatchString(strings.ToLower(value)) {
 				matched = true
 			}
+			redactedValues[i] = redactor.Header(header, value)
 		}
 
 		if expectedToMatch && !matched {
-			errors = append(errors, fmt.Errorf("response header \"%s\" has value(s) \"%s\", none of which match pattern \"%s\"", header, strings.Join(values[:], "\", \""), pattern))
+			errors = append(errors, headerAssertionError(response, header, fmt.Sprintf("response header \"%s\" has value(s) \"%s\", none of which match pattern \"%s\"", header, strings.Join(redactedValues, "\", \""), pattern), "matches "+pattern))
 		}
 
 		if !expectedToMatch && matched {
-			errors = append(errors, fmt.Errorf("response header \"%s\" has value(s) \"%s\", at least one of which matches pattern \"%s\"", header, strings.Join(values[:], "\", \""), pattern))
+			errors = append(errors, headerAssertionError(response, header, fmt.Sprintf("response header \"%s\" has value(s) \"%s\", at least one of which matches pattern \"%s\"", header, strings.Join(redactedValues, "\", \""), pattern), "not matching "+pattern))
 		}
 	}
 
 	return errors
 }
 
-func validateResponseBody(test *Test, response *http.Response, body []byte) []error {
+// headerAssertionError returns a failed header assertion showing all response headers
+func headerAssertionError(response *http.Response, header, message, expected string) error {
+	return &AssertionError{
+		Message:  message,
+		Expected: fmt.Sprintf("%s: %s", header, expected),
+		Headers:  response.Header,
+		Header:   header,
+	}
+}
+
+func validateResponseBody(test *Test, response *http.Response, body []byte, config *Config) []error {
 	errors := []error{}
 
 	patterns := test.Response.Body.Patterns
@@ -306,9 +475,24 @@ func validateResponseBody(test *Test, response *http.Response, body []byte) []er
 		}
 
 		if !re.Match(body) {
-			errors = append(errors, fmt.Errorf("response body does not match pattern \"%s\"", pattern))
+			errors = append(errors, &AssertionError{
+				Message:     fmt.Sprintf("response body does not match pattern \"%s\"", pattern),
+				Expected:    "matches " + pattern,
+				Body:        body,
+				ContentType: response.Header.Get("Content-Type"),
+			})
 		}
 	}
 
+	// Snapshot
+	if test.Response.Body.Snapshot != nil {
+		errors = append(errors, validateSnapshot(test, body, config)...)
+	}
+
+	// JSON Schema
+	if test.Response.Body.Schema != nil {
+		errors = append(errors, validateSchema(test, body)...)
+	}
+
 	return errors
 }
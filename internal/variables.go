@@ -0,0 +1,147 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/drone/envsubst"
+	"github.com/nytimes/httptest/functions"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+)
+
+// Variable is a value computed once per run, either a literal value or the
+// result of a dynamic header function
+type Variable struct {
+	Name     string   `yaml:"name"`
+	Value    string   `yaml:"value,omitempty"`
+	Function string   `yaml:"function,omitempty"`
+	Args     []string `yaml:"args,omitempty"`
+}
+
+// SetupFile holds variables shared by all test files
+type SetupFile struct {
+	Variables []Variable `yaml:"variables"`
+}
+
+// variableScope holds the variables of a test file or the setup file, which are
+// resolved once, after the variables of the parent scope
+type variableScope struct {
+	name      string
+	variables []Variable
+
+	once   sync.Once
+	values map[string]string
+	err    error
+}
+
+// resolve computes the values of the variables, which can refer to variables of
+// the parent scope and variables defined before them. Functions send HTTP
+// requests with transport.
+func (s *variableScope) resolve(parent *variableScope, transport http.RoundTripper) (map[string]string, error) {
+	s.once.Do(func() {
+		s.values = map[string]string{}
+		if parent != nil {
+			parentValues, err := parent.resolve(nil, transport)
+			if err != nil {
+				s.err = err
+				return
+			}
+			for name, value := range parentValues {
+				s.values[name] = value
+			}
+		}
+
+		for _, variable := range s.variables {
+			value, err := resolveVariable(variable, s.values, transport)
+			if err != nil {
+				s.err = fmt.Errorf("unable to resolve variable %s of %s: %s", variable.Name, s.name, err)
+				return
+			}
+			s.values[variable.Name] = value
+		}
+
+		if len(s.variables) > 0 {
+			zap.L().Info("resolved variables", zap.String("file", s.name), zap.Int("count", len(s.variables)))
+		}
+	})
+
+	return s.values, s.err
+}
+
+// resolveVariable computes the value of a variable. Like dynamic header args,
+// args can name variables defined before it.
+func resolveVariable(variable Variable, values map[string]string, transport http.RoundTripper) (string, error) {
+	if len(variable.Function) == 0 {
+		return renderTemplate(variable.Value, values, transport)
+	}
+
+	fn, ok := lookupFunction(variable.Function)
+	if !ok {
+		return "", fmt.Errorf("unknown function %s", variable.Function)
+	}
+
+	args := make([]string, len(variable.Args))
+	for i, arg := range variable.Args {
+		var err error
+		if args[i], err = renderTemplate(arg, values, transport); err != nil {
+			return "", err
+		}
+	}
+
+	// Functions see the variables so far in place of headers
+	headers := map[string]string{}
+	for name, value := range values {
+		headers[name] = value
+	}
+	return fn(&functions.RequestContext{Headers: headers, Transport: transport}, args)
+}
+
+// resolveAllVariables resolves the variables of all test files before tests are run
+func resolveAllVariables(tests []*Test, global *variableScope, transport http.RoundTripper) {
+	for _, test := range tests {
+		if test.variables != nil {
+			test.variables.resolve(global, transport)
+		}
+	}
+	if global != nil {
+		global.resolve(nil, transport)
+	}
+}
+
+// parseSetupFile parses the setup file with variables for all tests
+func parseSetupFile(filePath string) (*variableScope, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("ioutil: %v", err)
+	}
+
+	// Environment variable substitution
+	yamlString, err := envsubst.EvalEnv(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse file %s: %v", filePath, err)
+	}
+
+	sf := SetupFile{}
+	if err := yaml.Unmarshal([]byte(yamlString), &sf); err != nil {
+		return nil, fmt.Errorf("unable to parse file %s: %v", filePath, err)
+	}
+
+	return &variableScope{name: filePath, variables: sf.Variables}, nil
+}
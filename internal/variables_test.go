@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/nytimes/httptest/functions"
+)
+
+func TestVariableScopeResolve(t *testing.T) {
+	// Count calls to check values are computed once
+	calls := 0
+	funcMap["testCounter"] = func(request *functions.RequestContext, args []string) (string, error) {
+		calls++
+		return strconv.Itoa(calls), nil
+	}
+	defer delete(funcMap, "testCounter")
+
+	global := &variableScope{name: "setup.yml", variables: []Variable{
+		{Name: "token", Value: "abc"},
+		{Name: "counter", Function: "testCounter"},
+	}}
+	scope := &variableScope{name: "tests.yml", variables: []Variable{
+		{Name: "authorization", Function: "concat", Args: []string{"Bearer ", "token"}},
+		{Name: "path", Value: "/count/{{counter}}"},
+	}}
+
+	for i := 0; i < 2; i++ {
+		values, err := scope.resolve(global, nil)
+		if err != nil {
+			t.Fatalf("resolve: unexpected error %v", err)
+		}
+		if values["authorization"] != "Bearer abc" || values["path"] != "/count/1" {
+			t.Errorf("resolve: unexpected values %v", values)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("resolve: expected 1 call, actual %d", calls)
+	}
+
+	failing := &variableScope{name: "bad.yml", variables: []Variable{{Name: "x", Function: "unknown"}}}
+	if _, err := failing.resolve(nil, nil); err == nil {
+		t.Errorf("resolve: expected error for unknown function")
+	}
+}
+
+func TestApplyVariables(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tests.yml")
+	data := `
+variables:
+  - name: id
+    value: '42'
+tests:
+  - description: 'get order'
+    request:
+      path: '/orders/{{id}}'
+      headers:
+        x-user: '{{user}}'
+      dynamicHeaders:
+        - name: x-signature
+          function: concat
+          args: ['{{id}}-', '{{user}}']
+      query:
+        user: '{{user}}'
+      body: '{"id": {{id}}}'
+    response:
+      body:
+        patterns: ['"id":\s*{{id}}']
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests, err := parseTestFile(path)
+	if err != nil {
+		t.Fatalf("parseTestFile: unexpected error %v", err)
+	}
+
+	global := &variableScope{name: "setup.yml", variables: []Variable{{Name: "user", Value: "alice"}}}
+	test := tests[0]
+	if err := applyTemplates(test, global, nil); err != nil {
+		t.Fatalf("applyTemplates: unexpected error %v", err)
+	}
+	if err := preProcessTest(test, "example.com"); err != nil {
+		t.Fatalf("preProcessTest: unexpected error %v", err)
+	}
+
+	if test.Request.Path != "/orders/42?user=alice" || test.Request.Body != `{"id": 42}` {
+		t.Errorf("applyTemplates: unexpected path %s or body %s", test.Request.Path, test.Request.Body)
+	}
+	if test.Request.Headers["x-user"] != "alice" || test.Request.Headers["x-signature"] != "42-alice" {
+		t.Errorf("applyTemplates: unexpected headers %v", test.Request.Headers)
+	}
+	if test.Response.Body.Patterns[0] != `"id":\s*42` {
+		t.Errorf("applyTemplates: unexpected body pattern %s", test.Response.Body.Patterns[0])
+	}
+}
@@ -15,6 +15,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -22,7 +23,7 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
-	ht "github.com/nytimes/httptest/internal"
+	"github.com/nytimes/httptest/runner"
 )
 
 var (
@@ -36,7 +37,7 @@ var (
 	BuildTime string
 )
 
-func buildLogger(logLevel int) *zap.Logger {
+func buildLogger(logLevel int, redactor *runner.Redactor) *zap.Logger {
 	zapLevel := zap.FatalLevel
 	switch logLevel {
 	case 0:
@@ -47,59 +48,65 @@ func buildLogger(logLevel int) *zap.Logger {
 		zapLevel = zap.DebugLevel
 	}
 
-	config := zap.Config{
-		Level:       zap.NewAtomicLevelAt(zapLevel),
-		Development: false,
-		Encoding:    "json",
-		EncoderConfig: zapcore.EncoderConfig{
-			TimeKey:        "ts",
-			LevelKey:       "level",
-			NameKey:        "logger",
-			CallerKey:      zapcore.OmitKey,
-			FunctionKey:    zapcore.OmitKey,
-			MessageKey:     "msg",
-			StacktraceKey:  "stacktrace",
-			LineEnding:     zapcore.DefaultLineEnding,
-			EncodeLevel:    zapcore.LowercaseLevelEncoder,
-			EncodeTime:     zapcore.EpochTimeEncoder,
-			EncodeDuration: zapcore.SecondsDurationEncoder,
-			EncodeCaller:   zapcore.ShortCallerEncoder,
-		},
-		OutputPaths: []string{
-			"stderr",
-		},
-		ErrorOutputPaths: []string{
-			"stderr",
-		},
+	encoderConfig := zapcore.EncoderConfig{
+		TimeKey:        "ts",
+		LevelKey:       "level",
+		NameKey:        "logger",
+		CallerKey:      zapcore.OmitKey,
+		FunctionKey:    zapcore.OmitKey,
+		MessageKey:     "msg",
+		StacktraceKey:  "stacktrace",
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		EncodeTime:     zapcore.EpochTimeEncoder,
+		EncodeDuration: zapcore.SecondsDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
 	}
-	return zap.Must(config.Build())
+
+	// Redact secrets from everything written to the log
+	output := zapcore.Lock(zapcore.AddSync(redactor.Writer(os.Stderr)))
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), output, zap.NewAtomicLevelAt(zapLevel))
+
+	return zap.New(core, zap.ErrorOutput(output))
 }
 
 func main() {
+	// Run subcommand, if any
+	if ok, err := runCommand(os.Args[1:]); ok {
+		if err != nil {
+			log.Fatalf("error: %s", err)
+		}
+		os.Exit(0)
+	}
+
 	// Print version info
 	fmt.Printf("httptest: %s %s %s\n", BuildCommit, BuildBranch, BuildTime)
 
-	// Get and apply config
-	config, err := ht.FromEnv()
+	// Get config
+	config, err := runner.ConfigFromEnv()
 	if err != nil {
 		log.Fatalf("error: failed to parse config: %s", err)
 	}
 
-	if err := ht.ApplyConfig(config); err != nil {
-		log.Fatalf("error: failed to apply config: %s", err)
-	}
-
-	logger := buildLogger(config.Verbosity)
+	logger := buildLogger(config.Verbosity, config.Redactor)
 	defer logger.Sync()
 	zap.ReplaceGlobals(logger)
 
 	// Parse and run tests
-	tests, err := ht.ParseAllTestsInDirectory(config.TestDirectory)
+	tests, err := runner.LoadTests(config.TestDirectory)
 	if err != nil {
 		log.Fatalf("error: failed to parse tests: %s", err)
 	}
 
-	if !ht.RunTests(tests, config) {
+	report, err := runner.Run(context.Background(), tests, runner.Options{
+		Config:   config,
+		Reporter: runner.NewConsoleReporter(config),
+	})
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
+
+	if !report.OK() {
 		os.Exit(1)
 	}
 
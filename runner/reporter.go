@@ -0,0 +1,60 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"github.com/nytimes/httptest/internal"
+)
+
+// Reporter is told about the result of each test as it finishes, and about
+// the report once all tests are done. Calls don't overlap.
+type Reporter interface {
+	TestFinished(result *Result)
+	RunFinished(report *Report)
+}
+
+// consoleReporter prints results to stdout like the httptest command
+type consoleReporter struct {
+	failedOnly bool
+	redactor   *Redactor
+}
+
+// NewConsoleReporter returns a reporter that prints results to stdout like the
+// httptest command, as configured by TEST_PRINT_FAILED_ONLY and the redaction
+// settings of config
+func NewConsoleReporter(config *Config) Reporter {
+	return &consoleReporter{failedOnly: config.PrintFailedTestsOnly, redactor: config.Redactor}
+}
+
+func (r *consoleReporter) TestFinished(result *Result) {
+	if r.failedOnly && (result.Skipped || result.Passed()) {
+		return
+	}
+
+	internal.PrintTestResult(result.Test, &internal.TestResult{
+		Retries: result.Retries,
+		Skipped: result.Skipped,
+		Errors:  result.Errors,
+		Curl:    result.Curl,
+	}, r.redactor)
+}
+
+func (r *consoleReporter) RunFinished(report *Report) {
+	internal.PrintTestSummary(report.Passed, report.Failed, report.Skipped)
+
+	if report.OpenAPIOperations > 0 {
+		internal.PrintOpenAPICoverage(report.UntestedOperations, report.OpenAPIOperations)
+	}
+}
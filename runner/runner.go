@@ -0,0 +1,177 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package runner loads and runs httptest test files from Go programs and test
+// suites. The httptest command is a thin wrapper over it.
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nytimes/httptest/functions"
+	"github.com/nytimes/httptest/internal"
+)
+
+type (
+	// Test is a single test of a test file
+	Test = internal.Test
+
+	// Config holds the global configuration, as read from environment variables by ConfigFromEnv
+	Config = internal.Config
+
+	// Redactor hides sensitive values in output
+	Redactor = internal.Redactor
+
+	// AssertionError is a failed assertion, with what was expected and the actual value
+	AssertionError = internal.AssertionError
+)
+
+// Function is a dynamic header function. It is also available in variables and template expressions.
+type Function func(request *functions.RequestContext, args []string) (string, error)
+
+// Assertion checks a response for tests that call it in response.assertions,
+// with the args of the test. Returning an *AssertionError shows the expected
+// and actual values of a failure.
+type Assertion func(response *http.Response, body []byte, args []string) error
+
+// Options of a run
+type Options struct {
+	// Config of the run. Default: read from environment variables with ConfigFromEnv
+	Config *Config
+
+	// Reporter is told about each result and the report of the run. Default: nothing is reported
+	Reporter Reporter
+}
+
+// Result is the result of a single test
+type Result struct {
+	Test     *Test
+	Skipped  bool
+	Errors   []error
+	Retries  int
+	Duration time.Duration
+
+	// Curl is a curl command that sends the request of the test, with sensitive values redacted
+	Curl string
+}
+
+// Passed reports whether the test ran without errors
+func (r *Result) Passed() bool {
+	return !r.Skipped && len(r.Errors) == 0
+}
+
+// Report is the outcome of a run
+type Report struct {
+	// Results of the tests in the order they finished
+	Results []*Result
+
+	Passed  int
+	Failed  int
+	Skipped int
+
+	// OpenAPI coverage, if Config.OpenAPISpec is set
+	OpenAPIOperations  int
+	UntestedOperations []string
+}
+
+// OK reports whether no test failed
+func (r *Report) OK() bool {
+	return r.Failed == 0
+}
+
+// ConfigFromEnv returns the configuration read from environment variables
+func ConfigFromEnv() (*Config, error) {
+	return internal.FromEnv()
+}
+
+// LoadTests parses the tests of all test files in a directory and its
+// subdirectories, or of a single test file
+func LoadTests(path string) ([]*Test, error) {
+	return internal.ParseAllTestsInDirectory(path)
+}
+
+// RegisterFunction adds a dynamic header function. Names can't be reused.
+// Tests already running when it is called may not see the function.
+func RegisterFunction(name string, fn Function) error {
+	return internal.RegisterFunction(name, fn)
+}
+
+// RegisterAssertion adds an assertion that tests can call in
+// response.assertions. Names can't be reused. Tests already running when it
+// is called may not see the assertion.
+func RegisterAssertion(name string, fn Assertion) error {
+	return internal.RegisterAssertion(name, internal.AssertionFunc(fn))
+}
+
+// Run runs tests concurrently and returns the report. Tests that haven't
+// started when ctx is done are not run, and the report of the tests that did
+// is returned with the error of ctx. Tests are not changed by running them, so
+// they can be run again.
+func Run(ctx context.Context, tests []*Test, options Options) (*Report, error) {
+	config := options.Config
+	if config == nil {
+		var err error
+		if config, err = ConfigFromEnv(); err != nil {
+			return nil, fmt.Errorf("failed to parse config: %s", err)
+		}
+	}
+
+	if err := internal.ApplyConfig(config); err != nil {
+		return nil, fmt.Errorf("failed to apply config: %s", err)
+	}
+
+	report := &Report{}
+	runErr := internal.RunTests(ctx, tests, config, func(test *Test, testResult *internal.TestResult) {
+		result := &Result{
+			Test:     test,
+			Skipped:  testResult.Skipped,
+			Errors:   testResult.Errors,
+			Retries:  testResult.Retries,
+			Duration: testResult.Duration,
+			Curl:     testResult.Curl,
+		}
+
+		switch {
+		case result.Skipped:
+			report.Skipped++
+		case result.Passed():
+			report.Passed++
+		default:
+			report.Failed++
+		}
+		report.Results = append(report.Results, result)
+
+		if options.Reporter != nil {
+			options.Reporter.TestFinished(result)
+		}
+	})
+
+	if untested, total, ok := internal.OpenAPICoverage(config); ok {
+		report.OpenAPIOperations = total
+		report.UntestedOperations = untested
+	}
+
+	if options.Reporter != nil {
+		options.Reporter.RunFinished(report)
+	}
+
+	if err := internal.WriteHAR(config); err != nil {
+		return report, fmt.Errorf("failed to write HAR file: %s", err)
+	}
+
+	return report, runErr
+}
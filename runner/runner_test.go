@@ -0,0 +1,246 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nytimes/httptest/functions"
+)
+
+type recordingReporter struct {
+	results []*Result
+	report  *Report
+}
+
+func (r *recordingReporter) TestFinished(result *Result) {
+	r.results = append(r.results, result)
+}
+
+func (r *recordingReporter) RunFinished(report *Report) {
+	r.report = report
+}
+
+func writeTests(t *testing.T, data string) string {
+	path := filepath.Join(t.TempDir(), "tests.yml")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-echo", r.Header.Get("x-custom"))
+		w.Write([]byte(`{"items": [1, 2, 3]}`))
+	}))
+	defer server.Close()
+
+	err := RegisterFunction("runnerTestCustom", func(request *functions.RequestContext, args []string) (string, error) {
+		return "custom-" + strings.Join(args, "-"), nil
+	})
+	if err != nil {
+		t.Fatalf("RegisterFunction: unexpected error %v", err)
+	}
+	if err := RegisterFunction("runnerTestCustom", nil); err == nil {
+		t.Errorf("RegisterFunction: expected error for duplicate name")
+	}
+
+	err = RegisterAssertion("runnerTestItems", func(response *http.Response, body []byte, args []string) error {
+		if expected := fmt.Sprintf("[%s]", strings.Join(args, ", ")); !strings.Contains(string(body), expected) {
+			return &AssertionError{Message: "unexpected items", Expected: expected, Body: body}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RegisterAssertion: unexpected error %v", err)
+	}
+
+	path := writeTests(t, `
+tests:
+  - description: 'custom function and assertion'
+    request:
+      scheme: http
+      path: '/'
+      headers:
+        x-custom: '{{ runnerTestCustom(1, 2) }}'
+    response:
+      statusCodes: [200]
+      headers:
+        patterns:
+          x-echo: '^custom-1-2$'
+      assertions:
+        - name: runnerTestItems
+          args: ['1', '2', '3']
+  - description: 'failed assertion'
+    request:
+      scheme: http
+      path: '/'
+    response:
+      assertions:
+        - name: runnerTestItems
+          args: ['4']
+  - description: 'skipped'
+    conditions:
+      env:
+        RUNNER_TEST_UNSET: '^set$'
+    request:
+      path: '/'
+`)
+
+	tests, err := LoadTests(path)
+	if err != nil {
+		t.Fatalf("LoadTests: unexpected error %v", err)
+	}
+
+	reporter := &recordingReporter{}
+	report, err := Run(context.Background(), tests, Options{
+		Config:   &Config{Host: strings.TrimPrefix(server.URL, "http://"), Concurrency: 2},
+		Reporter: reporter,
+	})
+	if err != nil {
+		t.Fatalf("Run: unexpected error %v", err)
+	}
+
+	if report.Passed != 1 || report.Failed != 1 || report.Skipped != 1 || report.OK() {
+		t.Errorf("Run: unexpected report %+v", report)
+	}
+	if len(reporter.results) != 3 || reporter.report != report {
+		t.Errorf("Run: reporter expected 3 results and the report, actual %d results", len(reporter.results))
+	}
+
+	for _, result := range report.Results {
+		if result.Test.Description != "failed assertion" {
+			continue
+		}
+		if len(result.Errors) != 1 {
+			t.Fatalf("Run: expected 1 error, actual %v", result.Errors)
+		}
+		if _, ok := result.Errors[0].(*AssertionError); !ok {
+			t.Errorf("Run: expected *AssertionError, actual %T", result.Errors[0])
+		}
+		if !strings.HasPrefix(result.Curl, "curl") {
+			t.Errorf("Run: expected curl command, actual %q", result.Curl)
+		}
+	}
+}
+
+func TestRunUnknownAssertion(t *testing.T) {
+	path := writeTests(t, `
+tests:
+  - description: 'unknown assertion'
+    request:
+      path: '/'
+    response:
+      assertions:
+        - name: runnerTestUnknown
+`)
+
+	tests, err := LoadTests(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := Run(context.Background(), tests, Options{Config: &Config{Host: "example.com"}})
+	if err != nil {
+		t.Fatalf("Run: unexpected error %v", err)
+	}
+	if report.Failed != 1 || !strings.Contains(report.Results[0].Errors[0].Error(), "unknown assertion runnerTestUnknown") {
+		t.Errorf("Run: expected unknown assertion error, actual %+v", report.Results)
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	path := writeTests(t, `
+tests:
+  - description: 'not run'
+    request:
+      path: '/'
+`)
+
+	tests, err := LoadTests(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	report, err := Run(ctx, tests, Options{Config: &Config{Host: "example.com"}})
+	if err != context.Canceled {
+		t.Errorf("Run: expected context.Canceled, actual %v", err)
+	}
+	if report == nil || len(report.Results) != 0 {
+		t.Errorf("Run: expected no results, actual %+v", report)
+	}
+}
+
+func TestRunTwice(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.RawQuery != "a=b" || len(r.Header.Get("x-now")) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	path := writeTests(t, `
+tests:
+  - description: 'dynamic header and query'
+    request:
+      scheme: http
+      path: '/'
+      query:
+        a: b
+      headers:
+        X-Name: '{{ upper("value") }}'
+      dynamicHeaders:
+        - name: x-now
+          function: now
+    response:
+      statusCodes: [200]
+`)
+
+	tests, err := LoadTests(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Config{Host: strings.TrimPrefix(server.URL, "http://")}
+	for i := 0; i < 2; i++ {
+		report, err := Run(context.Background(), tests, Options{Config: config})
+		if err != nil || !report.OK() {
+			t.Fatalf("Run %d: expected pass, actual %v %+v", i, err, report.Results[0].Errors)
+		}
+	}
+
+	request := tests[0].Request
+	if request.Path != "/" || request.Headers["X-Name"] != `{{ upper("value") }}` || len(request.Host) > 0 {
+		t.Errorf("Run: test was changed %+v", request)
+	}
+}
+
+func TestRunConfigError(t *testing.T) {
+	path := writeTests(t, `
+tests:
+  - description: 'not run'
+    request:
+      path: '/'
+`)
+
+	tests, err := LoadTests(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A config that fails to apply fails every run
+	config := &Config{Host: "example.com", SetupFile: filepath.Join(t.TempDir(), "missing.yml")}
+	for i := 0; i < 2; i++ {
+		if report, err := Run(context.Background(), tests, Options{Config: config}); err == nil {
+			t.Errorf("Run %d: expected config error, actual %+v", i, report)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2019 The New York Times Company
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/nytimes/httptest/internal"
+)
+
+// TestingOptions configure RunT
+type TestingOptions struct {
+	// BaseURL tests are sent to, such as http://localhost:8080/api. Its scheme
+	// is the default of tests, and its path is prepended to their paths. Tests
+	// that set request.host keep their host, scheme and path.
+	BaseURL string
+
+	// Handler serves the tests in-process, on a server started for the run.
+	// It takes precedence over BaseURL.
+	Handler http.Handler
+
+	// Config of the run. Default: read from environment variables with ConfigFromEnv
+	Config *Config
+
+	// Parallel runs the subtests in parallel, as limited by the -parallel flag
+	// of go test rather than Config.Concurrency
+	Parallel bool
+}
+
+// RunT loads the tests of a test file or directory and runs each test as a
+// subtest of t named after its description, so they can be selected with the
+// -run flag of go test. Failed assertions are reported with t.Error, and
+// tests whose conditions aren't met are skipped.
+func RunT(t *testing.T, path string, options TestingOptions) {
+	t.Helper()
+
+	config := options.Config
+	if config == nil {
+		var err error
+		if config, err = ConfigFromEnv(); err != nil {
+			t.Fatalf("failed to parse config: %s", err)
+		}
+	}
+
+	baseURL := options.BaseURL
+	if options.Handler != nil {
+		server := httptest.NewServer(options.Handler)
+		t.Cleanup(server.Close)
+		baseURL = server.URL
+	}
+
+	base, err := url.Parse(baseURL)
+	if err != nil || len(base.Host) == 0 {
+		t.Fatalf("invalid base URL %q", baseURL)
+	}
+
+	// The base URL replaces TEST_HOST for this run only
+	runConfig := *config
+	runConfig.Host = base.Host
+	if err := internal.ApplyConfig(&runConfig); err != nil {
+		t.Fatalf("failed to apply config: %s", err)
+	}
+
+	tests, err := LoadTests(path)
+	if err != nil {
+		t.Fatalf("failed to parse tests: %s", err)
+	}
+
+	// Write the HAR file once all subtests, including parallel ones, are done
+	t.Cleanup(func() {
+		if err := internal.WriteHAR(&runConfig); err != nil {
+			t.Errorf("failed to write HAR file: %s", err)
+		}
+	})
+
+	prefix := strings.TrimSuffix(base.Path, "/")
+	for _, test := range tests {
+		if len(test.Request.Host) == 0 {
+			if len(test.Request.Scheme) == 0 {
+				test.Request.Scheme = base.Scheme
+			}
+			test.Request.Path = prefix + test.Request.Path
+		}
+
+		t.Run(test.Description, func(t *testing.T) {
+			if options.Parallel {
+				t.Parallel()
+			}
+			reportT(t, test, internal.RunTest(context.Background(), test, &runConfig), runConfig.Redactor)
+		})
+	}
+}
+
+// reportT reports the result of a test to its subtest
+func reportT(t testing.TB, test *Test, result *internal.TestResult, redactor *Redactor) {
+	t.Helper()
+	if result.Skipped {
+		t.Skipf("conditions of %s not met", test.Filename)
+		return
+	}
+
+	for _, err := range result.Errors {
+		message := redactor.String(err.Error())
+
+		// Show what was expected next to the actual response
+		if assertionErr, ok := err.(*AssertionError); ok {
+			message += "\n" + assertionErr.View(redactor)
+		}
+		t.Error(message)
+	}
+
+	if t.Failed() && len(result.Curl) > 0 {
+		t.Logf("request:\n%s", result.Curl)
+	}
+}
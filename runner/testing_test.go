@@ -0,0 +1,134 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nytimes/httptest/internal"
+)
+
+func TestRunT(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Served with and without the path of the base URL
+		switch strings.TrimPrefix(r.URL.Path, "/api") {
+		case "/orders":
+			w.Header().Set("content-type", "application/json")
+			w.Write([]byte(`{"orders": []}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	path := writeTests(t, `
+tests:
+  - description: 'list orders'
+    request:
+      path: '/orders'
+    response:
+      statusCodes: [200]
+      headers:
+        patterns:
+          content-type: '^application/json$'
+      body:
+        patterns: ['"orders"']
+  - description: 'unknown path'
+    request:
+      path: '/unknown'
+    response:
+      statusCodes: [404]
+  - description: 'skipped'
+    conditions:
+      env:
+        RUNNER_TEST_UNSET: '^set$'
+    request:
+      path: '/orders'
+`)
+
+	t.Run("handler", func(t *testing.T) {
+		RunT(t, path, TestingOptions{Handler: handler, Config: &Config{}})
+	})
+
+	t.Run("base URL", func(t *testing.T) {
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+		RunT(t, path, TestingOptions{BaseURL: server.URL + "/api/", Config: &Config{}, Parallel: true})
+	})
+}
+
+// recordingTB records the failures and logs reported to a test
+type recordingTB struct {
+	testing.TB
+	errors []string
+	logs   []string
+}
+
+func (t *recordingTB) Helper() {}
+
+func (t *recordingTB) Error(args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprint(args...))
+}
+
+func (t *recordingTB) Logf(format string, args ...interface{}) {
+	t.logs = append(t.logs, fmt.Sprintf(format, args...))
+}
+
+func (t *recordingTB) Failed() bool {
+	return len(t.errors) > 0
+}
+
+func TestRunTFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("set-cookie", "session=SUPERSECRETSESSION")
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	path := writeTests(t, `
+tests:
+  - description: 'failing'
+    request:
+      path: '/'
+      headers:
+        authorization: 'Bearer SECRETTOKEN'
+    response:
+      statusCodes: [200]
+      headers:
+        patterns:
+          set-cookie: '^theme='
+`)
+	tests, err := LoadTests(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redactor, err := internal.NewRedactor(nil, nil, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{Host: strings.TrimPrefix(server.URL, "http://"), Redactor: redactor}
+	if err := internal.ApplyConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	tests[0].Request.Scheme = "http"
+
+	recorder := &recordingTB{TB: t}
+	reportT(recorder, tests[0], internal.RunTest(context.Background(), tests[0], config), redactor)
+
+	if len(recorder.errors) != 2 {
+		t.Fatalf("reportT: expected status and header failures, actual %q", recorder.errors)
+	}
+	if !strings.Contains(recorder.errors[0], "unexpected status code") || !strings.Contains(recorder.errors[1], `"set-cookie" has value(s) "[REDACTED]"`) {
+		t.Errorf("reportT: unexpected failures %q", recorder.errors)
+	}
+	output := strings.Join(append(recorder.errors, recorder.logs...), "\n")
+	if strings.Contains(output, "SUPERSECRETSESSION") || strings.Contains(output, "SECRETTOKEN") {
+		t.Errorf("reportT: secrets in output %s", output)
+	}
+	if len(recorder.logs) != 1 || !strings.Contains(recorder.logs[0], "curl") {
+		t.Errorf("reportT: expected curl command, actual %q", recorder.logs)
+	}
+}